@@ -0,0 +1,96 @@
+package hashblog
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// batchSize is the number of keys GetBatch hashes and touches before it
+// starts probing. It needs to be big enough that the loads overlap, but small
+// enough that the groups we touch are still in cache when we come to use them.
+const batchSize = 16
+
+// GetBatch looks up each of keys, setting out[i] to the value for keys[i] and
+// found[i] to whether keys[i] is present. out and found must be at least as
+// long as keys.
+//
+// Get finishes one lookup before starting the next, so when the table doesn't
+// fit in cache we stall on one cache miss per key. GetBatch instead hashes a
+// batch of keys up front, then loads the control bytes of each key's first
+// group. Those loads don't depend on each other, so the CPU can have many cache
+// misses in flight at once. By the time we probe each key its group is
+// hopefully already in cache.
+//
+// That only helps when the groups aren't already in cache. A full table is
+// under 1MB, so if you're looking up lots of keys in one table it's probably
+// in L2, and GetBatch is no faster than calling Get for each key, or a few ns
+// slower. GetBatch pays off when lookups are spread over more data than fits
+// in cache, say across many tables: in BenchmarkGetBatchCold it takes about
+// 75ns a key to Get's 100ns once the tables add up to more than L2.
+func (m *SwissTable[K, V]) GetBatch(keys []K, out []V, found []bool) {
+	if m == nil {
+		clear(out[:len(keys)])
+		clear(found[:len(keys)])
+		return
+	}
+	var hashes [batchSize]hashValue
+	for len(keys) > 0 {
+		n := min(len(keys), batchSize)
+		for i, key := range keys[:n] {
+			hashes[i] = hash(key)
+		}
+		touchGroups(m.groups[:], hashes[:n])
+		for i, key := range keys[:n] {
+			out[i], found[i] = m.get(key, hashes[i])
+		}
+		keys, out, found = keys[n:], out[n:], found[n:]
+	}
+}
+
+// GetBatch looks up each of keys, setting out[i] to the value for keys[i] and
+// found[i] to whether keys[i] is present. out and found must be at least as
+// long as keys.
+//
+// See SwissTable.GetBatch for how this works.
+func (m *SwissConcrete) GetBatch(keys []string, out []int, found []bool) {
+	if m == nil {
+		clear(out[:len(keys)])
+		clear(found[:len(keys)])
+		return
+	}
+	var hashes [batchSize]hashValue
+	for len(keys) > 0 {
+		n := min(len(keys), batchSize)
+		for i, key := range keys[:n] {
+			hashes[i] = concreteHash(key)
+		}
+		touchGroups(m.groups[:], hashes[:n])
+		for i, key := range keys[:n] {
+			out[i], found[i] = m.get(key, hashes[i])
+		}
+		keys, out, found = keys[n:], out[n:], found[n:]
+	}
+}
+
+// touchGroups loads the first byte of the first group each of hashes will
+// probe, to pull those groups into cache. Every group type starts with its
+// control bytes, which are what the probe looks at first. len(groups) must be
+// a power of two.
+//
+// Go has no prefetch instruction, so these are ordinary loads. They still let
+// the misses overlap: none of them depends on another, so the CPU starts them
+// all without waiting. We don't need the bytes we load, so we keep their sum
+// alive to stop the compiler discarding the loads. The sum has to stay local: storing it in a
+// global would make GetBatch, which only reads the table, race with itself.
+//
+// We only share this part of GetBatch between the tables. Passing each table's
+// hash and get to a shared loop as func values stops them being inlined, and
+// costs more per key than the prefetching saves.
+func touchGroups[G any](groups []G, hashes []hashValue) {
+	mask := hashValue(len(groups) - 1)
+	var sum byte
+	for _, h := range hashes {
+		sum += *(*byte)(unsafe.Pointer(&groups[(h>>7)&mask]))
+	}
+	runtime.KeepAlive(sum)
+}
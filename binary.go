@@ -0,0 +1,255 @@
+package hashblog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// The binary encoding of a table is a header:
+//
+//	magic      4 bytes  "hbst"
+//	version    1 byte
+//	kind       1 byte   which type of table wrote it
+//	reserved   2 bytes
+//	entries    uint32
+//
+// followed by the key and value of each entry. Integers in the header are
+// little-endian.
+//
+// The encoding leaves out the groups' layout. A table could only be loaded
+// group by group with the hash seed it was built with, and there's no seed we
+// could record: Go doesn't let us read or set the seeds the tables use.
+// maphash.Seed is opaque, and runtime.memhash uses a seed the runtime chooses
+// when the program starts. A table loaded by another process hashes every key
+// differently, so UnmarshalBinary always rehashes the keys, and control bytes
+// would just be thrown away. Package swissfile uses its own hash, with a seed
+// stored in the file, for tables that have to be loaded without rehashing.
+const (
+	binaryMagic      = "hbst"
+	binaryVersion    = 3
+	binaryHeaderSize = 4 + 4 + 4
+)
+
+// Table kinds in the binary header.
+const (
+	kindSwissTable byte = iota + 1
+	kindSwissConcrete
+	kindDoubleSwiss
+)
+
+// ErrBinaryFormat is returned by UnmarshalBinary when the data isn't a valid
+// encoding of a table.
+var ErrBinaryFormat = errors.New("invalid table encoding")
+
+func appendBinaryHeader(b []byte, kind byte, entries int) []byte {
+	b = append(b, binaryMagic...)
+	b = append(b, binaryVersion, kind, 0, 0)
+	return binary.LittleEndian.AppendUint32(b, uint32(entries))
+}
+
+// tableDecoder reads the binary encoding of a table.
+type tableDecoder struct {
+	kind    byte
+	entries int
+	// data is the data not yet decoded.
+	data []byte
+}
+
+// newTableDecoder reads the header from data. kind is the kind of table we're
+// decoding into. accept lists other kinds of table with compatible keys and
+// values.
+func newTableDecoder(data []byte, kind byte, accept ...byte) (*tableDecoder, error) {
+	if len(data) < binaryHeaderSize || string(data[:4]) != binaryMagic {
+		return nil, fmt.Errorf("%w: no header", ErrBinaryFormat)
+	}
+	if data[4] != binaryVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrBinaryFormat, data[4])
+	}
+	d := &tableDecoder{
+		kind:    data[5],
+		entries: int(binary.LittleEndian.Uint32(data[8:])),
+		data:    data[binaryHeaderSize:],
+	}
+	if d.kind != kind && !slices.Contains(accept, d.kind) {
+		return nil, fmt.Errorf("%w: can't decode table kind %d into kind %d", ErrBinaryFormat, d.kind, kind)
+	}
+	// Inserting into a table with no empty slots left would never finish.
+	if d.entries >= simpleTableSize {
+		return nil, fmt.Errorf("%w: too many entries (%d)", ErrBinaryFormat, d.entries)
+	}
+	return d, nil
+}
+
+// each calls fn for every entry in the data. fn must decode the entry's key and
+// value.
+func (d *tableDecoder) each(fn func() error) error {
+	for range d.entries {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	if len(d.data) != 0 {
+		return fmt.Errorf("%w: %d bytes after the last entry", ErrBinaryFormat, len(d.data))
+	}
+	return nil
+}
+
+// decode decodes a value from d with c.
+func decode[T any](d *tableDecoder, c Codec[T]) (v T, err error) {
+	v, n, err := c.Decode(d.data)
+	if err != nil {
+		return v, fmt.Errorf("%w: %w", ErrBinaryFormat, err)
+	}
+	if n < 0 || n > len(d.data) {
+		return v, fmt.Errorf("%w: codec used %d bytes of %d", ErrBinaryFormat, n, len(d.data))
+	}
+	d.data = d.data[n:]
+	return v, nil
+}
+
+// SetCodecs sets the codecs MarshalBinary and UnmarshalBinary use for the
+// table's keys and values. If either is nil, or SetCodecs isn't called, they
+// use StringCodec, IntCodec, UintCodec or Float64Codec if the type is one those
+// handle.
+func (m *SwissTable[K, V]) SetCodecs(keys Codec[K], values Codec[V]) {
+	m.keyCodec, m.valueCodec = keys, values
+}
+
+func (m *SwissTable[K, V]) codecs() (Codec[K], Codec[V], error) {
+	kc, vc := m.keyCodec, m.valueCodec
+	if kc == nil {
+		if kc = defaultCodec[K](); kc == nil {
+			return nil, nil, fmt.Errorf("no codec for keys of type %s: call SetCodecs", reflect.TypeFor[K]())
+		}
+	}
+	if vc == nil {
+		if vc = defaultCodec[V](); vc == nil {
+			return nil, nil, fmt.Errorf("no codec for values of type %s: call SetCodecs", reflect.TypeFor[V]())
+		}
+	}
+	return kc, vc, nil
+}
+
+// MarshalBinary encodes the table's entries. See UnmarshalBinary.
+func (m *SwissTable[K, V]) MarshalBinary() ([]byte, error) {
+	return m.AppendBinary(nil)
+}
+
+// AppendBinary appends the encoding of the table to b. See MarshalBinary.
+func (m *SwissTable[K, V]) AppendBinary(b []byte) ([]byte, error) {
+	kc, vc, err := m.codecs()
+	if err != nil {
+		return b, err
+	}
+	b = appendBinaryHeader(b, kindSwissTable, m.len)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl >= 0x80 {
+				continue
+			}
+			if b, err = kc.Append(b, g.entries[i].key); err != nil {
+				return b, fmt.Errorf("encoding key: %w", err)
+			}
+			if b, err = vc.Append(b, g.entries[i].value); err != nil {
+				return b, fmt.Errorf("encoding value: %w", err)
+			}
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary replaces the contents of the table with the table encoded in
+// data, hashing each key and inserting it again. The new table has no deleted
+// markers, whatever the encoded one had.
+//
+// If UnmarshalBinary returns an error it leaves the table empty.
+func (m *SwissTable[K, V]) UnmarshalBinary(data []byte) error {
+	m.Clear()
+	kc, vc, err := m.codecs()
+	if err != nil {
+		return err
+	}
+	d, err := newTableDecoder(data, kindSwissTable)
+	if err != nil {
+		return err
+	}
+
+	err = d.each(func() error {
+		key, err := decode(d, kc)
+		if err != nil {
+			return err
+		}
+		value, err := decode(d, vc)
+		if err != nil {
+			return err
+		}
+		if e := m.insert(key, value, hash(key), true); e != nil {
+			e.value = value
+		}
+		return nil
+	})
+	if err != nil {
+		m.Clear()
+		return err
+	}
+	if m.bloom != nil {
+		m.rebuildBloom()
+	}
+	return nil
+}
+
+// MarshalBinary encodes the table. See SwissTable.MarshalBinary.
+func (m *SwissConcrete) MarshalBinary() ([]byte, error) {
+	return m.AppendBinary(nil)
+}
+
+// AppendBinary appends the encoding of the table to b.
+func (m *SwissConcrete) AppendBinary(b []byte) ([]byte, error) {
+	b = appendBinaryHeader(b, kindSwissConcrete, m.len)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i := range groupSize {
+			if g.ctrl.get(i) >= 0x80 {
+				continue
+			}
+			b, _ = StringCodec{}.Append(b, g.entries[i].key)
+			b, _ = IntCodec[int]{}.Append(b, g.entries[i].value)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary replaces the contents of the table with the table encoded in
+// data. It can also decode a DoubleSwiss. See SwissTable.UnmarshalBinary.
+func (m *SwissConcrete) UnmarshalBinary(data []byte) error {
+	m.Clear()
+	d, err := newTableDecoder(data, kindSwissConcrete, kindDoubleSwiss)
+	if err != nil {
+		return err
+	}
+
+	err = d.each(func() error {
+		key, err := decode(d, Codec[string](StringCodec{}))
+		if err != nil {
+			return err
+		}
+		value, err := decode(d, Codec[int](IntCodec[int]{}))
+		if err != nil {
+			return err
+		}
+		m.Set(key, value)
+		return nil
+	})
+	if err != nil {
+		m.Clear()
+		return err
+	}
+	if m.bloom != nil {
+		m.rebuildBloom()
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package hashblog_test
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		new  func() mapper
+	}{
+		{"SwissTable", func() mapper { return hashblog.NewSwissTable[string, int]() }},
+		{"SwissConcrete", func() mapper { return hashblog.NewSwissConcrete() }},
+		{"DoubleSwiss", func() mapper { return hashblog.NewDoubleSwiss() }},
+		{"SwissTableBloom", func() mapper { return newSwissTableBloom() }},
+		{"SwissConcreteBloom", func() mapper { return newSwissConcreteBloom() }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m := test.new()
+			for i := range 20000 {
+				m.Set(strconv.Itoa(i), i)
+			}
+			// Leave some deleted markers.
+			for i := 0; i < 20000; i += 3 {
+				m.(deleter).Delete(strconv.Itoa(i))
+			}
+			data, err := m.(encoding.BinaryMarshaler).MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := test.new()
+			// Unmarshalling replaces what's there.
+			got.Set("old", 1)
+			if err := got.(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			for i := range 20000 {
+				v, ok := got.Get(strconv.Itoa(i))
+				if want := i%3 != 0; ok != want || (ok && v != i) {
+					t.Fatalf("Get(%d) = %d, %t", i, v, ok)
+				}
+			}
+			if _, ok := got.Get("old"); ok {
+				t.Fatalf("old entry still present")
+			}
+			// Rehashing drops the deleted markers.
+			if s := got.(interface{ Stats() hashblog.Stats }).Stats(); s.Tombstones != 0 {
+				t.Fatalf("rehashed table still has %d deleted slots", s.Tombstones)
+			}
+		})
+	}
+}
+
+func TestBinaryCrossDecode(t *testing.T) {
+	sc := hashblog.NewSwissConcrete()
+	for i := range 1000 {
+		sc.Set(strconv.Itoa(i), i)
+	}
+	data, err := sc.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SwissConcrete and DoubleSwiss have the same keys and values, so each can
+	// decode the other.
+	ds := hashblog.NewDoubleSwiss()
+	if err := ds.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = ds.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	back := hashblog.NewSwissConcrete()
+	if err := back.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 1000 {
+		if v, ok := back.Get(strconv.Itoa(i)); !ok || v != i {
+			t.Fatalf("Get(%d) = %d, %t", i, v, ok)
+		}
+	}
+
+	// The kind byte says a DoubleSwiss wrote this. A SwissTable's keys and
+	// values may use any codecs, so it only decodes what a SwissTable wrote.
+	st := hashblog.NewSwissTable[string, int]()
+	if err := st.UnmarshalBinary(data); !errors.Is(err, hashblog.ErrBinaryFormat) {
+		t.Fatalf("expected ErrBinaryFormat, got %v", err)
+	}
+}
+
+func TestBinaryCodecs(t *testing.T) {
+	m := hashblog.NewSwissTable[int64, float64]()
+	for i := range 1000 {
+		m.Set(int64(i)-500, float64(i)/3)
+	}
+	// AppendBinary appends.
+	data, err := m.AppendBinary([]byte("prefix"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("prefix")) {
+		t.Fatalf("AppendBinary didn't append")
+	}
+	got := hashblog.NewSwissTable[int64, float64]()
+	if err := got.UnmarshalBinary(data[len("prefix"):]); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 1000 {
+		if v, ok := got.Get(int64(i) - 500); !ok || v != float64(i)/3 {
+			t.Fatalf("Get(%d) = %v, %t", i-500, v, ok)
+		}
+	}
+
+	// Values that overflow the type they're decoded into are an error.
+	small := hashblog.NewSwissTable[int64, int8]()
+	if err := small.UnmarshalBinary(data[len("prefix"):]); !errors.Is(err, hashblog.ErrBinaryFormat) {
+		t.Fatalf("expected ErrBinaryFormat, got %v", err)
+	}
+
+	// There's no default codec for structs.
+	pm := hashblog.NewSwissTable[point, string]()
+	pm.Set(point{1, 2}, "a")
+	pm.Set(point{-3, 4}, "b")
+	if _, err := pm.MarshalBinary(); err == nil {
+		t.Fatalf("expected an error without a codec for point")
+	}
+	pm.SetCodecs(pointCodec{}, nil)
+	data, err = pm.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgot := hashblog.NewSwissTable[point, string]()
+	pgot.SetCodecs(pointCodec{}, nil)
+	if err := pgot.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := pgot.Get(point{-3, 4}); !ok || v != "b" {
+		t.Fatalf("Get = %q, %t", v, ok)
+	}
+}
+
+type point struct{ x, y int32 }
+
+// pointCodec encodes a point as 8 bytes.
+type pointCodec struct{}
+
+func (pointCodec) Append(b []byte, p point) ([]byte, error) {
+	b = binary.LittleEndian.AppendUint32(b, uint32(p.x))
+	return binary.LittleEndian.AppendUint32(b, uint32(p.y)), nil
+}
+
+func (pointCodec) Decode(b []byte) (p point, n int, err error) {
+	if len(b) < 8 {
+		return p, 0, errors.New("short")
+	}
+	p.x = int32(binary.LittleEndian.Uint32(b))
+	p.y = int32(binary.LittleEndian.Uint32(b[4:]))
+	return p, 8, nil
+}
+
+func TestBinaryCorrupt(t *testing.T) {
+	m := hashblog.NewSwissConcrete()
+	for i := range 100 {
+		m.Set(strconv.Itoa(i), i)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const headerSize = 12
+
+	for _, test := range []struct {
+		name    string
+		corrupt func(b []byte) []byte
+	}{
+		{"empty", func(b []byte) []byte { return nil }},
+		{"magic", func(b []byte) []byte { b[0] = 'x'; return b }},
+		{"version", func(b []byte) []byte { b[4] = 99; return b }},
+		{"kind", func(b []byte) []byte { b[5] = 99; return b }},
+		{"too many entries", func(b []byte) []byte { b[9] = 0xFF; return b }},
+		{"more entries", func(b []byte) []byte { b[8]++; return b }},
+		{"fewer entries", func(b []byte) []byte { b[8]--; return b }},
+		{"truncated", func(b []byte) []byte { return b[:len(b)-1] }},
+		{"truncated header", func(b []byte) []byte { return b[:headerSize-1] }},
+		{"trailing", func(b []byte) []byte { return append(b, 0) }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := hashblog.NewSwissConcrete()
+			got.Set("old", 1)
+			err := got.UnmarshalBinary(test.corrupt(bytes.Clone(data)))
+			if !errors.Is(err, hashblog.ErrBinaryFormat) {
+				t.Fatalf("expected ErrBinaryFormat, got %v", err)
+			}
+			if _, ok := got.Get("old"); ok {
+				t.Fatalf("expected the table to be empty after an error")
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshalBinary(b *testing.B) {
+	m := hashblog.NewSwissConcrete()
+	for i := range 24000 {
+		m.Set(strconv.Itoa(i), i)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	got := hashblog.NewSwissConcrete()
+	for range b.N {
+		if err := got.UnmarshalBinary(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package hashblog
+
+// bloomFilter is a blocked Bloom filter. It says whether a key might be in a
+// table, so lookups for keys that definitely aren't present can skip the table
+// entirely.
+//
+// A classic Bloom filter sets k bits scattered across the whole filter for each
+// key, so checking a key costs k cache misses. A blocked filter sets all k bits
+// in one block. Here a block is a single uint64, so checking a key is one load
+// and a compare. The cost is a higher false positive rate for the same number
+// of bits: about 3% with the table full and 1% at 60% full.
+//
+// Bloom filters can't forget keys. Deleted keys stay in the filter, making it
+// less useful, until it is rebuilt from the keys still in the table.
+type bloomFilter struct {
+	words [bloomWords]uint64
+	// added is the number of keys added to the filter since it was last built,
+	// including the ones it was built with. deleted is the number of those
+	// that have since been deleted from the table.
+	added, deleted int
+}
+
+// bloomWords is the size of the filter: 8 bits for each slot in the table.
+// That's 32KB, small enough to stay in cache.
+const bloomWords = simpleTableSize * 8 / 64
+
+// bloomPos returns the word for a key with hash h, and the bits to set in it.
+//
+// The tables use the bottom 19 bits of the hash to choose a group and control
+// byte. We use the bits above those, so whether a key is in the filter doesn't
+// depend on where it is in the table.
+func bloomPos(h hashValue) (word int, mask uint64) {
+	word = int(h>>20) & (bloomWords - 1)
+	mask = 1<<((h>>32)&63) | 1<<((h>>38)&63) | 1<<((h>>44)&63) | 1<<((h>>50)&63)
+	return word, mask
+}
+
+func (b *bloomFilter) add(h hashValue) {
+	word, mask := bloomPos(h)
+	b.words[word] |= mask
+	b.added++
+}
+
+// mayContain reports whether a key with hash h may have been added. If it
+// returns false the key definitely wasn't.
+func (b *bloomFilter) mayContain(h hashValue) bool {
+	word, mask := bloomPos(h)
+	return b.words[word]&mask == mask
+}
+
+// bloomMinRebuild is the fewest deleted keys we rebuild the filter for.
+// Rebuilding walks all 32768 slots however few keys there are, so for a table
+// with only a few keys a quarter of them going isn't enough to pay for it. A
+// couple of thousand deleted keys set few enough bits that they barely change
+// the false positive rate.
+const bloomMinRebuild = simpleTableSize / 16
+
+// remove records that a key has been deleted, and reports whether enough keys
+// have been deleted that the filter should be rebuilt. We rebuild once a
+// quarter of the keys in the filter have gone, and at least bloomMinRebuild of
+// them. Rebuilding hashes every key in the table, so rebuilding any more often
+// would make deletes expensive.
+func (b *bloomFilter) remove() (rebuild bool) {
+	b.deleted++
+	return b.deleted*4 > b.added && b.deleted >= bloomMinRebuild
+}
+
+// EnableBloomFilter adds a Bloom filter to the table, built from the keys
+// already in it. Get checks the filter before looking at the table, so most
+// lookups for keys that aren't present don't touch the table at all. This
+// helps when most lookups miss, and costs a little when most hit.
+//
+// The filter uses 32KB. Set adds keys to it, and Delete rebuilds it once a
+// quarter of the keys in it, and at least 2048, have been deleted.
+func (m *SwissTable[K, V]) EnableBloomFilter() {
+	m.bloom = &bloomFilter{}
+	m.rebuildBloom()
+}
+
+func (m *SwissTable[K, V]) rebuildBloom() {
+	*m.bloom = bloomFilter{}
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl < 0x80 {
+				m.bloom.add(hash(g.entries[i].key))
+			}
+		}
+	}
+}
+
+// EnableBloomFilter adds a Bloom filter to the table. See
+// SwissTable.EnableBloomFilter.
+func (m *SwissConcrete) EnableBloomFilter() {
+	m.bloom = &bloomFilter{}
+	m.rebuildBloom()
+}
+
+func (m *SwissConcrete) rebuildBloom() {
+	*m.bloom = bloomFilter{}
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i := range groupSize {
+			if g.ctrl.get(i) < 0x80 {
+				m.bloom.add(concreteHash(g.entries[i].key))
+			}
+		}
+	}
+}
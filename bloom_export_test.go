@@ -0,0 +1,6 @@
+package hashblog
+
+// BloomMayContain reports whether key passes the table's Bloom filter.
+func (m *SwissConcrete) BloomMayContain(key string) bool {
+	return m.bloom.mayContain(concreteHash(key))
+}
@@ -0,0 +1,171 @@
+package hashblog_test
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+func newSwissTableBloom() *hashblog.SwissTable[string, int] {
+	m := hashblog.NewSwissTable[string, int]()
+	m.EnableBloomFilter()
+	return m
+}
+
+func newSwissConcreteBloom() *hashblog.SwissConcrete {
+	m := hashblog.NewSwissConcrete()
+	m.EnableBloomFilter()
+	return m
+}
+
+func TestBloomFalsePositives(t *testing.T) {
+	m := newSwissConcreteBloom()
+	for i := range 20000 {
+		m.Set(strconv.Itoa(i), i)
+	}
+	for i := range 20000 {
+		if !m.BloomMayContain(strconv.Itoa(i)) {
+			t.Fatalf("key %d is in the table but not the filter", i)
+		}
+	}
+	// At this load we expect about 1% false positives.
+	if fp := bloomFalsePositives(m, 20000, 120000); fp > 0.02 {
+		t.Fatalf("expected under 2%% false positives, got %.2f%%", fp*100)
+	}
+}
+
+// bloomFalsePositives returns the fraction of keys from start to end that pass
+// the filter. None of them should be in the table.
+func bloomFalsePositives(m *hashblog.SwissConcrete, start, end int) float64 {
+	var n int
+	for i := start; i < end; i++ {
+		if m.BloomMayContain(strconv.Itoa(i)) {
+			n++
+		}
+	}
+	return float64(n) / float64(end-start)
+}
+
+func TestBloomRebuild(t *testing.T) {
+	m := newSwissConcreteBloom()
+	for i := range 20000 {
+		m.Set(strconv.Itoa(i), i)
+	}
+	// Deleting keys rebuilds the filter once a quarter of the keys in it have
+	// been deleted. So after deleting half the keys no more than a quarter of
+	// the keys in the filter are deleted ones.
+	for i := 0; i < 20000; i += 2 {
+		m.Delete(strconv.Itoa(i))
+	}
+	var passed int
+	for i := 0; i < 20000; i += 2 {
+		if m.BloomMayContain(strconv.Itoa(i)) {
+			passed++
+		}
+	}
+	if passed > 10000/4 {
+		t.Fatalf("expected most deleted keys to be gone from the filter, but %d of 10000 pass", passed)
+	}
+	for i := 1; i < 20000; i += 2 {
+		if v, ok := m.Get(strconv.Itoa(i)); !ok || v != i {
+			t.Fatalf("expected key %d to have value %d, got %d, %t", i, i, v, ok)
+		}
+	}
+
+	// Clear empties the filter.
+	m.Clear()
+	if fp := bloomFalsePositives(m, 0, 20000); fp != 0 {
+		t.Fatalf("expected nothing to pass the filter after Clear, got %.2f%%", fp*100)
+	}
+}
+
+func TestBloomNoRebuildWhenSmall(t *testing.T) {
+	m := newSwissConcreteBloom()
+	for i := range 10 {
+		m.Set(strconv.Itoa(i), i)
+	}
+	// Rebuilding walks the whole table, so deleting a few keys from a small
+	// table shouldn't rebuild the filter, and the deleted keys still pass it.
+	for i := range 5 {
+		m.Delete(strconv.Itoa(i))
+	}
+	for i := range 10 {
+		if !m.BloomMayContain(strconv.Itoa(i)) {
+			t.Fatalf("key %d should still be in the filter", i)
+		}
+	}
+
+	// Churning through enough keys does rebuild it.
+	for i := 10; i < 10+2048; i++ {
+		m.Set(strconv.Itoa(i), i)
+		m.Delete(strconv.Itoa(i - 5))
+	}
+	if fp := bloomFalsePositives(m, 0, 2000); fp > 0.01 {
+		t.Fatalf("expected deleted keys to be gone from the filter, but %.2f%% pass", fp*100)
+	}
+}
+
+func TestBloomEnableLater(t *testing.T) {
+	m := hashblog.NewSwissTable[string, int]()
+	for i := range 1000 {
+		m.Set(strconv.Itoa(i), i)
+	}
+	m.EnableBloomFilter()
+	for i := range 2000 {
+		if v, ok := m.Get(strconv.Itoa(i)); ok != (i < 1000) || (ok && v != i) {
+			t.Fatalf("unexpected result for key %d: %d, %t", i, v, ok)
+		}
+	}
+}
+
+// BenchmarkBloom looks up a mix of keys that are in the table and keys that
+// aren't, with and without a Bloom filter.
+func BenchmarkBloom(b *testing.B) {
+	const size = 16000
+	keys := make([]string, size*2)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	for _, hitRatio := range []float64{0, 0.1, 0.5, 0.9, 1} {
+		// Spread the lookups for keys that are present evenly through the
+		// lookups for keys that aren't.
+		lookups := make([]string, size)
+		for i := range lookups {
+			if int(float64(i)*hitRatio) != int(float64(i+1)*hitRatio) {
+				lookups[i] = keys[i]
+			} else {
+				lookups[i] = keys[size+i]
+			}
+		}
+
+		b.Run(fmt.Sprintf("hit=%g", hitRatio), func(b *testing.B) {
+			for _, test := range []struct {
+				name string
+				m    mapper
+			}{
+				{"Swiss", hashblog.NewSwissTable[string, int]()},
+				{"SwissBloom", newSwissTableBloom()},
+				{"SwissConcrete", hashblog.NewSwissConcrete()},
+				{"SwissConcreteBloom", newSwissConcreteBloom()},
+			} {
+				m := test.m
+				b.Run("i="+test.name, func(b *testing.B) {
+					for i, key := range keys[:size] {
+						m.Set(key, i)
+					}
+					b.ReportAllocs()
+					b.ResetTimer()
+					for b.Loop() {
+						for _, key := range lookups {
+							m.Get(key)
+						}
+					}
+					b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+				})
+			}
+		})
+	}
+}
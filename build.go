@@ -0,0 +1,115 @@
+package hashblog
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// DuplicatePolicy says what BuildSwiss does when a key appears more than once.
+type DuplicatePolicy int
+
+const (
+	// LastWins keeps the value from the last occurrence of the key, just as
+	// repeated calls to Set would.
+	LastWins DuplicatePolicy = iota
+	// FirstWins keeps the value from the first occurrence of the key.
+	FirstWins
+	// ErrorOnDuplicate makes BuildSwiss fail if any key is repeated.
+	ErrorOnDuplicate
+	// AssumeUnique is a promise from the caller that there are no duplicate
+	// keys, so BuildSwiss doesn't check. If the promise is broken the table
+	// will contain more than one entry for the key, and which one Get finds is
+	// undefined.
+	AssumeUnique
+)
+
+// maxBuildEntries is the most keys BuildSwiss will put in a table. If we filled
+// every slot there would be no empty slots left to end the probe sequence for a
+// missing key, and Get would never return. Like the Go runtime we stop at 7/8
+// full.
+const maxBuildEntries = groupTableSize * groupSize * 7 / 8
+
+var (
+	ErrDuplicateKey   = errors.New("duplicate key")
+	ErrTooManyEntries = errors.New("too many entries for table")
+)
+
+// BuildSwiss builds a SwissTable containing keys[i] => values[i] for every i.
+//
+// The main saving over calling Set for each key comes when policy is
+// AssumeUnique: we skip looking for an existing entry for each key and just
+// take the first empty slot in its probe sequence.
+//
+// Keys are inserted in the order given, so FirstWins and LastWins see
+// duplicates of a key in the order they appear in keys. BuildSwiss doesn't
+// sort the keys into the groups they'll go in first. A full table is under
+// 1MB, so it usually fits in L2 and inserting out of group order costs little.
+// Sorting costs an extra pass over the keys, scratch space, and reading keys
+// and values out of order. In BenchmarkBuild a counting sort by group made
+// building a 28000 key table take 1.97ms rather than 1.12ms. The table holds at
+// most 28672 distinct keys, 7/8 of its slots. BuildSwiss fails with
+// ErrTooManyEntries if there are more.
+func BuildSwiss[K comparable, V any](keys []K, values []V, policy DuplicatePolicy) (*SwissTable[K, V], error) {
+	if len(keys) != len(values) {
+		return nil, fmt.Errorf("have %d keys but %d values", len(keys), len(values))
+	}
+
+	m := NewSwissTable[K, V]()
+	var n int
+	for i, key := range keys {
+		value := values[i]
+		e := m.insert(key, value, hash(key), policy != AssumeUnique)
+		if e == nil {
+			// We count keys as we add them rather than checking len(keys),
+			// so duplicates don't count towards the limit. We stop well
+			// before the table fills, so insert always finds an empty slot.
+			if n++; n > maxBuildEntries {
+				return nil, fmt.Errorf("%w: more than %d distinct keys", ErrTooManyEntries, maxBuildEntries)
+			}
+			continue
+		}
+		switch policy {
+		case LastWins:
+			e.value = value
+		case ErrorOnDuplicate:
+			return nil, fmt.Errorf("%w: %v", ErrDuplicateKey, key)
+		}
+	}
+	return m, nil
+}
+
+// insert adds key and value to the table, given the hash h of key. If
+// checkExisting is true and the key is already present insert leaves the table
+// unchanged and returns the existing entry. Otherwise it returns nil.
+//
+// insert puts the key in the first empty slot on its probe sequence, passing
+// any deleted markers, and doesn't add it to the Bloom filter. That suits
+// building a table or rehashing one.
+func (m *SwissTable[K, V]) insert(key K, value V, h hashValue, checkExisting bool) *entry[K, V] {
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101010101010101
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		if checkExisting {
+			matches := g.ctrl.findMatches(h1Expanded)
+			for matches != 0 {
+				i := bits.TrailingZeros64(matches) / 8
+				if e := &g.entries[i]; e.key == key {
+					return e
+				}
+				matches &= matches - 1
+			}
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			i := bits.TrailingZeros64(empties) / 8
+			g.entries[i] = entry[K, V]{key: key, value: value}
+			g.ctrl[i] = h1
+			m.len++
+			return nil
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package hashblog_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+func TestBuildSwiss(t *testing.T) {
+	keys := make([]string, 20000)
+	values := make([]int, len(keys))
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		values[i] = i
+	}
+
+	for _, policy := range []hashblog.DuplicatePolicy{
+		hashblog.LastWins,
+		hashblog.FirstWins,
+		hashblog.ErrorOnDuplicate,
+		hashblog.AssumeUnique,
+	} {
+		m, err := hashblog.BuildSwiss(keys, values, policy)
+		if err != nil {
+			t.Fatalf("policy %d: unexpected error %v", policy, err)
+		}
+		for i, key := range keys {
+			if val, ok := m.Get(key); !ok || val != i {
+				t.Fatalf("policy %d: expected key %s to have value %d, got %d, %t", policy, key, i, val, ok)
+			}
+		}
+		if _, ok := m.Get("missing"); ok {
+			t.Fatalf("policy %d: expected missing key to return ok == false", policy)
+		}
+	}
+}
+
+func TestBuildSwissDuplicates(t *testing.T) {
+	keys := []string{"a", "b", "a", "c", "a"}
+	values := []int{1, 2, 3, 4, 5}
+
+	m, err := hashblog.BuildSwiss(keys, values, hashblog.LastWins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := m.Get("a"); val != 5 {
+		t.Fatalf("last wins: expected value 5, got %d", val)
+	}
+
+	m, err = hashblog.BuildSwiss(keys, values, hashblog.FirstWins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := m.Get("a"); val != 1 {
+		t.Fatalf("first wins: expected value 1, got %d", val)
+	}
+
+	if _, err := hashblog.BuildSwiss(keys, values, hashblog.ErrorOnDuplicate); !errors.Is(err, hashblog.ErrDuplicateKey) {
+		t.Fatalf("expected ErrDuplicateKey, got %v", err)
+	}
+}
+
+func TestBuildSwissTooMany(t *testing.T) {
+	keys := make([]int, 32768)
+	for i := range keys {
+		keys[i] = i
+	}
+	if _, err := hashblog.BuildSwiss(keys, keys, hashblog.AssumeUnique); !errors.Is(err, hashblog.ErrTooManyEntries) {
+		t.Fatalf("expected ErrTooManyEntries, got %v", err)
+	}
+	if _, err := hashblog.BuildSwiss(keys, keys[:10], hashblog.AssumeUnique); err == nil {
+		t.Fatalf("expected an error for mismatched lengths")
+	}
+
+	// Only distinct keys count towards the limit.
+	dups := make([]int, 40000)
+	for i := range dups {
+		dups[i] = i % 28000
+	}
+	for _, policy := range []hashblog.DuplicatePolicy{hashblog.FirstWins, hashblog.LastWins} {
+		m, err := hashblog.BuildSwiss(dups, dups, policy)
+		if err != nil {
+			t.Fatalf("policy %d: unexpected error %v", policy, err)
+		}
+		if v, ok := m.Get(27999); !ok || v != 27999 {
+			t.Fatalf("policy %d: Get(27999) = %d, %t", policy, v, ok)
+		}
+	}
+}
+
+func BenchmarkBuild(b *testing.B) {
+	keys := make([]string, 28000)
+	values := make([]int, len(keys))
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		values[i] = i
+	}
+
+	b.Run("i=Set", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			m := hashblog.NewSwissTable[string, int]()
+			for i, key := range keys {
+				m.Set(key, values[i])
+			}
+		}
+	})
+	b.Run("i=BuildLastWins", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := hashblog.BuildSwiss(keys, values, hashblog.LastWins); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("i=BuildAssumeUnique", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := hashblog.BuildSwiss(keys, values, hashblog.AssumeUnique); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,190 @@
+// Command hashbench runs a workload against each of the hashblog tables and
+// the built-in map, and prints how long each took.
+//
+// The benchmarks in the hashblog package only use keys from strconv.Itoa, and
+// only do one kind of operation at a time. hashbench lets you choose the shape
+// of the keys, how they are picked, and the mix of reads, writes and deletes.
+//
+//	go run ./cmd/hashbench -keys 20000 -keytype url -access zipf -mix 90:9:1 -hit 0.5
+//
+// Every table runs exactly the same operations. Tables that can't delete keys
+// are left out if the mix includes deletes.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/philpearl/hashblog"
+)
+
+func main() {
+	var w workload
+	flag.IntVar(&w.Keys, "keys", 20000, fmt.Sprintf("number of keys to load into each table (at most %d)", maxKeys))
+	flag.StringVar(&w.KeyType, "keytype", "sequential", "shape of the keys: sequential, random or url")
+	flag.IntVar(&w.KeyLen, "keylen", 8, "length of random keys, and minimum length of sequential keys")
+	flag.StringVar(&w.Access, "access", "uniform", "how keys are picked for each operation: uniform, sequential or zipf")
+	flag.Float64Var(&w.ZipfS, "zipf-s", 1.1, "s parameter of the Zipf distribution, greater than 1")
+	mix := flag.String("mix", "100:0:0", "relative proportions of reads, writes and deletes")
+	flag.Float64Var(&w.HitRatio, "hit", 1, "fraction of reads for keys that are in the table")
+	flag.IntVar(&w.Ops, "ops", 1_000_000, "number of operations to run")
+	flag.Uint64Var(&w.Seed, "seed", 1, "random seed")
+	format := flag.String("format", "table", "output format: table or csv")
+	flag.Parse()
+
+	if err := run(os.Stdout, &w, *mix, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "hashbench: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(out io.Writer, w *workload, mix, format string) error {
+	if err := w.parseMix(mix); err != nil {
+		return err
+	}
+	if err := w.validate(); err != nil {
+		return err
+	}
+	if format != "table" && format != "csv" {
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	load, ops := w.generate()
+	var results []result
+	for _, t := range tables {
+		m := t.new()
+		if w.Deletes > 0 && m.delete == nil {
+			continue
+		}
+		results = append(results, measure(t.name, m, load, ops))
+	}
+
+	if format == "csv" {
+		return writeCSV(out, results)
+	}
+	return writeTable(out, results)
+}
+
+// table is the operations hashbench needs from a table. The tables don't share
+// an interface for Delete, so we use funcs rather than an interface. delete is
+// nil if the table can't delete keys.
+type table struct {
+	set    func(key string, value int)
+	get    func(key string) (int, bool)
+	delete func(key string)
+}
+
+func wrap[M interface {
+	Set(key string, value int)
+	Get(key string) (int, bool)
+}](m M) table {
+	t := table{set: m.Set, get: m.Get}
+	if d, ok := any(m).(interface{ Delete(key string) }); ok {
+		t.delete = d.Delete
+	}
+	return t
+}
+
+var tables = []struct {
+	name string
+	new  func() table
+}{
+	{"SimpleTable", func() table { return wrap(hashblog.NewSimpleTable[string, int]()) }},
+	{"SimpleTableProbe", func() table { return wrap(hashblog.NewSimpleTableProbe[string, int]()) }},
+	{"GroupTable", func() table { return wrap(hashblog.NewGroupTable[string, int]()) }},
+	{"GroupTableCtrl", func() table { return wrap(hashblog.NewGroupTableCtrl[string, int]()) }},
+	{"SwissTable", func() table { return wrap(hashblog.NewSwissTable[string, int]()) }},
+	{"SwissConcrete", func() table { return wrap(hashblog.NewSwissConcrete()) }},
+	{"DoubleSwiss", func() table { return wrap(hashblog.NewDoubleSwiss()) }},
+	{"SwissArena", func() table { return wrap(hashblog.NewSwissArena()) }},
+	{"map", func() table { return wrap(builtinMap{}) }},
+}
+
+type builtinMap map[string]int
+
+func (m builtinMap) Set(key string, value int) { m[key] = value }
+func (m builtinMap) Get(key string) (int, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+func (m builtinMap) Delete(key string) { delete(m, key) }
+
+type result struct {
+	name        string
+	nsPerOp     float64
+	allocsPerOp float64
+	bytesPerOp  float64
+	// hits is the number of reads that found their key. It should be the same
+	// for every table.
+	hits int
+}
+
+// measure loads the empty table t with load, then times running ops against
+// it.
+func measure(name string, t table, load []string, ops []op) result {
+	for i, key := range load {
+		t.set(key, i)
+	}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	var hits int
+	for i, o := range ops {
+		switch o.kind {
+		case opRead:
+			if _, ok := t.get(o.key); ok {
+				hits++
+			}
+		case opWrite:
+			t.set(o.key, i)
+		case opDelete:
+			t.delete(o.key)
+		}
+	}
+
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	n := float64(len(ops))
+	return result{
+		name:        name,
+		nsPerOp:     float64(elapsed.Nanoseconds()) / n,
+		allocsPerOp: float64(after.Mallocs-before.Mallocs) / n,
+		bytesPerOp:  float64(after.TotalAlloc-before.TotalAlloc) / n,
+		hits:        hits,
+	}
+}
+
+func writeTable(out io.Writer, results []result) error {
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "table\tns/op\tallocs/op\tB/op\thits\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%.2f\t%.3f\t%.1f\t%d\t\n", r.name, r.nsPerOp, r.allocsPerOp, r.bytesPerOp, r.hits)
+	}
+	return tw.Flush()
+}
+
+func writeCSV(out io.Writer, results []result) error {
+	cw := csv.NewWriter(out)
+	cw.Write([]string{"table", "ns/op", "allocs/op", "B/op", "hits"})
+	for _, r := range results {
+		cw.Write([]string{
+			r.name,
+			strconv.FormatFloat(r.nsPerOp, 'f', 2, 64),
+			strconv.FormatFloat(r.allocsPerOp, 'f', 3, 64),
+			strconv.FormatFloat(r.bytesPerOp, 'f', 1, 64),
+			strconv.Itoa(r.hits),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// maxKeys is the most keys we'll put in a table. The tables are a fixed size
+// of 32768 slots and can't grow. As they fill up probe sequences get very long,
+// and a full table never finds an empty slot to end a lookup.
+const maxKeys = 32768 * 7 / 8
+
+// workload describes the operations to run against each table.
+type workload struct {
+	// Keys is the number of distinct keys loaded into the table.
+	Keys int
+	// KeyType is the shape of the keys: "sequential", "random" or "url".
+	KeyType string
+	// KeyLen is the length of random keys, and the minimum length of
+	// sequential keys, which are padded with leading zeros. URL keys ignore
+	// it.
+	KeyLen int
+	// Access is how keys are picked for each operation: "uniform",
+	// "sequential" or "zipf".
+	Access string
+	// ZipfS is the s parameter of the Zipf distribution. It must be > 1.
+	// Larger values concentrate the operations on fewer keys.
+	ZipfS float64
+	// Reads, Writes and Deletes are the relative proportions of each
+	// operation.
+	Reads, Writes, Deletes int
+	// HitRatio is the fraction of reads for keys that were loaded into the
+	// table.
+	HitRatio float64
+	// Ops is the number of operations.
+	Ops int
+	// Seed seeds the random numbers, so the same workload is reproducible.
+	Seed uint64
+}
+
+func (w *workload) validate() error {
+	switch {
+	case w.Keys < 1 || w.Keys > maxKeys:
+		return fmt.Errorf("keys must be between 1 and %d as the tables can't grow", maxKeys)
+	case w.KeyType != "sequential" && w.KeyType != "random" && w.KeyType != "url":
+		return fmt.Errorf("unknown key type %q", w.KeyType)
+	case w.KeyLen < 1:
+		return fmt.Errorf("key length must be at least 1")
+	case w.KeyType == "random" && math.Pow(float64(len(keyLetters)), float64(w.KeyLen)) < 4*float64(w.Keys):
+		// generate needs 2*Keys distinct keys, and picks them at random until
+		// it has them. Asking for at most half the possible keys keeps that
+		// from taking too long.
+		return fmt.Errorf("random keys of length %d have fewer than %d possible values", w.KeyLen, 4*w.Keys)
+	case w.Access != "uniform" && w.Access != "sequential" && w.Access != "zipf":
+		return fmt.Errorf("unknown access pattern %q", w.Access)
+	case w.Access == "zipf" && w.ZipfS <= 1:
+		return fmt.Errorf("zipf s must be greater than 1")
+	case w.Reads < 0 || w.Writes < 0 || w.Deletes < 0 || w.Reads+w.Writes+w.Deletes == 0:
+		return fmt.Errorf("mix must have non-negative proportions that aren't all zero")
+	case w.HitRatio < 0 || w.HitRatio > 1:
+		return fmt.Errorf("hit ratio must be between 0 and 1")
+	case w.Ops < 1:
+		return fmt.Errorf("ops must be at least 1")
+	}
+	return nil
+}
+
+// parseMix parses a read:write:delete mix such as "90:9:1".
+func (w *workload) parseMix(mix string) error {
+	parts := strings.Split(mix, ":")
+	if len(parts) != 3 {
+		return fmt.Errorf("mix %q should be reads:writes:deletes, e.g. 90:9:1", mix)
+	}
+	var vals [3]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return fmt.Errorf("mix %q: %w", mix, err)
+		}
+		vals[i] = v
+	}
+	w.Reads, w.Writes, w.Deletes = vals[0], vals[1], vals[2]
+	return nil
+}
+
+type opKind uint8
+
+const (
+	opRead opKind = iota
+	opWrite
+	opDelete
+)
+
+type op struct {
+	kind opKind
+	key  string
+}
+
+// generate makes the keys to load into each table, and the operations to run
+// against it.
+//
+// Writes and deletes only use the loaded keys, so the table never holds more
+// than w.Keys keys. Reads that should miss use a separate set of keys that are
+// never loaded. Reads of a key that has been deleted also miss, so with deletes
+// in the mix the real hit ratio is a little lower than w.HitRatio.
+func (w *workload) generate() (load []string, ops []op) {
+	r := rand.New(rand.NewPCG(w.Seed, w.Seed))
+
+	// Generate twice as many keys as we need. The first half are loaded and
+	// the second half are only used for misses.
+	keys := make([]string, 0, 2*w.Keys)
+	seen := make(map[string]struct{}, 2*w.Keys)
+	for i := 0; len(keys) < 2*w.Keys; i++ {
+		key := w.makeKey(r, i)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	load, misses := keys[:w.Keys], keys[w.Keys:]
+
+	var zipf *rand.Zipf
+	if w.Access == "zipf" {
+		zipf = rand.NewZipf(r, w.ZipfS, 1, uint64(w.Keys-1))
+	}
+	var next int
+	pick := func() int {
+		switch w.Access {
+		case "sequential":
+			i := next
+			next = (next + 1) % w.Keys
+			return i
+		case "zipf":
+			return int(zipf.Uint64())
+		default:
+			return r.IntN(w.Keys)
+		}
+	}
+
+	total := w.Reads + w.Writes + w.Deletes
+	ops = make([]op, w.Ops)
+	for i := range ops {
+		k := pick()
+		switch n := r.IntN(total); {
+		case n < w.Reads:
+			if r.Float64() < w.HitRatio {
+				ops[i] = op{kind: opRead, key: load[k]}
+			} else {
+				ops[i] = op{kind: opRead, key: misses[k]}
+			}
+		case n < w.Reads+w.Writes:
+			ops[i] = op{kind: opWrite, key: load[k]}
+		default:
+			ops[i] = op{kind: opDelete, key: load[k]}
+		}
+	}
+	return load, ops
+}
+
+// makeKey makes the i'th key. It may make the same key more than once.
+func (w *workload) makeKey(r *rand.Rand, i int) string {
+	switch w.KeyType {
+	case "sequential":
+		s := strconv.Itoa(i)
+		if len(s) < w.KeyLen {
+			s = strings.Repeat("0", w.KeyLen-len(s)) + s
+		}
+		return s
+	case "url":
+		return urlKey(r)
+	default:
+		b := make([]byte, w.KeyLen)
+		for j := range b {
+			b[j] = keyLetters[r.IntN(len(keyLetters))]
+		}
+		return string(b)
+	}
+}
+
+const keyLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+var (
+	urlHosts = []string{"example.com", "www.example.org", "api.example.net", "static.example.io", "shop.example.co.uk"}
+	urlWords = []string{"users", "products", "orders", "search", "images", "v1", "v2", "items", "blog", "posts", "tags", "account", "settings", "cart", "reviews"}
+)
+
+// urlKey makes a URL-like key. URLs share long common prefixes, which is
+// different from the short distinct keys the other key types make.
+func urlKey(r *rand.Rand) string {
+	var b strings.Builder
+	b.WriteString("https://")
+	b.WriteString(urlHosts[r.IntN(len(urlHosts))])
+	for range 1 + r.IntN(4) {
+		b.WriteByte('/')
+		b.WriteString(urlWords[r.IntN(len(urlWords))])
+	}
+	b.WriteByte('/')
+	b.WriteString(strconv.Itoa(r.IntN(1_000_000)))
+	if r.IntN(2) == 0 {
+		b.WriteString("?ref=")
+		b.WriteString(urlWords[r.IntN(len(urlWords))])
+	}
+	return b.String()
+}
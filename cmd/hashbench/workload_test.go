@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	good := workload{Keys: 1000, KeyType: "random", KeyLen: 8, Access: "zipf", ZipfS: 1.1, Reads: 1, HitRatio: 0.5, Ops: 100}
+	if err := good.validate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for _, change := range []func(w *workload){
+		func(w *workload) { w.Keys = 0 },
+		func(w *workload) { w.Keys = maxKeys + 1 },
+		func(w *workload) { w.KeyType = "uuid" },
+		func(w *workload) { w.KeyLen = 0 },
+		func(w *workload) { w.KeyLen = 2 },
+		func(w *workload) { w.Access = "hotspot" },
+		func(w *workload) { w.ZipfS = 1 },
+		func(w *workload) { w.Reads = 0 },
+		func(w *workload) { w.Writes = -1 },
+		func(w *workload) { w.HitRatio = 1.5 },
+		func(w *workload) { w.Ops = 0 },
+	} {
+		w := good
+		change(&w)
+		if err := w.validate(); err == nil {
+			t.Errorf("expected an error for %#v", w)
+		}
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	var w workload
+	if err := w.parseMix("90:9:1"); err != nil {
+		t.Fatal(err)
+	}
+	if w.Reads != 90 || w.Writes != 9 || w.Deletes != 1 {
+		t.Fatalf("unexpected mix %d:%d:%d", w.Reads, w.Writes, w.Deletes)
+	}
+	for _, mix := range []string{"", "90:10", "a:b:c", "1:2:3:4"} {
+		if err := w.parseMix(mix); err == nil {
+			t.Errorf("expected an error for mix %q", mix)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	for _, keyType := range []string{"sequential", "random", "url"} {
+		t.Run(keyType, func(t *testing.T) {
+			w := workload{Keys: 1000, KeyType: keyType, KeyLen: 6, Access: "uniform", Reads: 80, Writes: 10, Deletes: 10, HitRatio: 0.25, Ops: 100_000, Seed: 1}
+			load, ops := w.generate()
+			if len(load) != w.Keys {
+				t.Fatalf("expected %d keys to load, got %d", w.Keys, len(load))
+			}
+			loaded := make(map[string]bool, len(load))
+			for _, key := range load {
+				if loaded[key] {
+					t.Fatalf("key %q loaded twice", key)
+				}
+				loaded[key] = true
+				switch keyType {
+				case "url":
+					if !strings.HasPrefix(key, "https://") {
+						t.Fatalf("key %q doesn't look like a URL", key)
+					}
+				default:
+					if len(key) < w.KeyLen {
+						t.Fatalf("key %q is shorter than %d", key, w.KeyLen)
+					}
+				}
+			}
+
+			var counts [3]int
+			var hits int
+			for _, o := range ops {
+				counts[o.kind]++
+				if o.kind == opRead && loaded[o.key] {
+					hits++
+				}
+				if o.kind != opRead && !loaded[o.key] {
+					t.Fatalf("write or delete of key %q that isn't loaded", o.key)
+				}
+			}
+			checkFraction(t, "reads", counts[opRead], len(ops), 0.8)
+			checkFraction(t, "writes", counts[opWrite], len(ops), 0.1)
+			checkFraction(t, "deletes", counts[opDelete], len(ops), 0.1)
+			checkFraction(t, "hits", hits, counts[opRead], 0.25)
+
+			// The same seed gives the same workload.
+			_, again := w.generate()
+			for i := range ops {
+				if ops[i] != again[i] {
+					t.Fatalf("op %d differs between runs with the same seed", i)
+				}
+			}
+		})
+	}
+}
+
+func checkFraction(t *testing.T, what string, n, total int, want float64) {
+	t.Helper()
+	if got := float64(n) / float64(total); math.Abs(got-want) > 0.01 {
+		t.Errorf("expected %.2f of %s, got %.3f", want, what, got)
+	}
+}
+
+func TestGenerateZipf(t *testing.T) {
+	w := workload{Keys: 1000, KeyType: "sequential", KeyLen: 1, Access: "zipf", ZipfS: 1.5, Reads: 1, HitRatio: 1, Ops: 100_000, Seed: 1}
+	load, ops := w.generate()
+	counts := make(map[string]int)
+	for _, o := range ops {
+		counts[o.key]++
+	}
+	// The first key is by far the most popular.
+	if counts[load[0]] < len(ops)/3 {
+		t.Fatalf("expected the first key to get at least a third of the operations, got %d", counts[load[0]])
+	}
+	if counts[load[0]] < 2*counts[load[1]] {
+		t.Fatalf("expected the first key to be much more popular than the second: %d and %d", counts[load[0]], counts[load[1]])
+	}
+}
+
+func TestRun(t *testing.T) {
+	w := workload{Keys: 1000, KeyType: "random", KeyLen: 8, Access: "uniform", HitRatio: 0.5, Ops: 10_000, Seed: 1}
+
+	var out bytes.Buffer
+	if err := run(&out, &w, "80:10:10", "csv"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the tables that can delete keys run a workload with deletes.
+	want := []string{"table", "GroupTableCtrl", "SwissTable", "SwissConcrete", "DoubleSwiss", "SwissArena", "map"}
+	if len(records) != len(want) {
+		t.Fatalf("expected %d rows, got %d: %v", len(want), len(records), records)
+	}
+	for i, r := range records {
+		if r[0] != want[i] {
+			t.Errorf("expected row %d to be %s, got %s", i, want[i], r[0])
+		}
+	}
+	// Every table sees the same operations, so finds the same keys.
+	for _, r := range records[2:] {
+		if r[4] != records[1][4] {
+			t.Errorf("%s found %s keys, but %s found %s", r[0], r[4], records[1][0], records[1][4])
+		}
+	}
+
+	out.Reset()
+	if err := run(&out, &w, "100:0:0", "table"); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "\n"); n != len(tables)+1 {
+		t.Fatalf("expected a header and a line for each of %d tables, got\n%s", len(tables), out.String())
+	}
+
+	if err := run(&out, &w, "100:0:0", "xml"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,102 @@
+// Command hashviz fills one of the hashblog tables with keys and draws its
+// control bytes, so you can see how the table fills up and where the long
+// probe sequences are.
+//
+//	go run ./cmd/hashviz -table swiss -keys 28000 -delete-every 3 -format html -o swiss.html
+//
+// The keys are the decimal numbers from 0 up to -keys. If -delete-every is set,
+// every nth key is deleted afterwards, leaving deleted slots behind.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/philpearl/hashblog"
+)
+
+type config struct {
+	Table       string
+	Keys        int
+	DeleteEvery int
+	Format      hashblog.VizFormat
+}
+
+func main() {
+	var c config
+	flag.StringVar(&c.Table, "table", "swiss", "table to draw: groupctrl, swiss or doubleswiss")
+	flag.IntVar(&c.Keys, "keys", 20000, "number of keys to add. The tables hold 32768 entries and can't grow")
+	flag.IntVar(&c.DeleteEvery, "delete-every", 0, "if not zero, delete every nth key after adding them")
+	format := flag.String("format", string(hashblog.VizSVG), "output format: svg or html")
+	output := flag.String("o", "", "output file (defaults to stdout)")
+	flag.Parse()
+	c.Format = hashblog.VizFormat(*format)
+
+	if err := run(c, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "hashviz: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(c config, output string) error {
+	if output == "" {
+		w := bufio.NewWriter(os.Stdout)
+		if err := draw(w, c); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := draw(f, c); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type table interface {
+	Set(key string, value int)
+	Delete(key string)
+	Visualize(w io.Writer, format hashblog.VizFormat) error
+}
+
+func draw(w io.Writer, c config) error {
+	var m table
+	switch c.Table {
+	case "groupctrl":
+		m = hashblog.NewGroupTableCtrl[string, int]()
+	case "swiss":
+		m = hashblog.NewSwissTable[string, int]()
+	case "doubleswiss":
+		// Without SIMD support this is a SwissConcrete.
+		m = hashblog.NewDoubleSwiss()
+	default:
+		return fmt.Errorf("unknown table %q", c.Table)
+	}
+	// The tables are full at 32768 keys, and Set never returns if there's no
+	// room for a new key.
+	if c.Keys < 0 || c.Keys >= 32768 {
+		return fmt.Errorf("keys must be between 0 and 32767, not %d", c.Keys)
+	}
+	if c.DeleteEvery < 0 {
+		return fmt.Errorf("delete-every can't be negative")
+	}
+
+	for i := range c.Keys {
+		m.Set(strconv.Itoa(i), i)
+	}
+	if c.DeleteEvery > 0 {
+		for i := 0; i < c.Keys; i += c.DeleteEvery {
+			m.Delete(strconv.Itoa(i))
+		}
+	}
+	return m.Visualize(w, c.Format)
+}
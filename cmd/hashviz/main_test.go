@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDraw(t *testing.T) {
+	for _, table := range []string{"groupctrl", "swiss", "doubleswiss"} {
+		var buf bytes.Buffer
+		if err := draw(&buf, config{Table: table, Keys: 100, DeleteEvery: 10, Format: "svg"}); err != nil {
+			t.Fatalf("%s: %v", table, err)
+		}
+		if n := strings.Count(buf.String(), "probe length"); n != 90 {
+			t.Errorf("%s: expected 90 keys drawn, got %d", table, n)
+		}
+	}
+}
+
+func TestDrawErrors(t *testing.T) {
+	for _, c := range []config{
+		{Table: "btree", Keys: 100, Format: "svg"},
+		{Table: "swiss", Keys: 32768, Format: "svg"},
+		{Table: "swiss", Keys: -1, Format: "svg"},
+		{Table: "swiss", Keys: 100, DeleteEvery: -1, Format: "svg"},
+		{Table: "swiss", Keys: 100, Format: "gif"},
+	} {
+		if err := draw(&bytes.Buffer{}, c); err == nil {
+			t.Errorf("expected an error for %#v", c)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"math/bits"
+	"strings"
+	"text/template"
+)
+
+// config describes the table to generate.
+type config struct {
+	// Type is the name of the table type.
+	Type string
+	// Key and Value are the key and value types.
+	Key   string
+	Value string
+	// Package is the package the code is generated into.
+	Package string
+	// Groups is the number of groups in the table.
+	Groups int
+}
+
+func (c config) validate() error {
+	switch {
+	case !token.IsIdentifier(c.Type):
+		return fmt.Errorf("type name %q is not a valid identifier", c.Type)
+	case c.Key == "":
+		return errors.New("no key type given")
+	case c.Value == "":
+		return errors.New("no value type given")
+	case !token.IsIdentifier(c.Package):
+		return fmt.Errorf("package name %q is not a valid identifier", c.Package)
+	case c.Groups <= 0 || bits.OnesCount(uint(c.Groups)) != 1:
+		return fmt.Errorf("number of groups (%d) must be a positive power of two", c.Groups)
+	}
+	return nil
+}
+
+// Prefix is used to name the unexported helpers for the table, so we can
+// generate more than one table into a package.
+func (c config) Prefix() string {
+	return strings.ToLower(c.Type[:1]) + c.Type[1:]
+}
+
+// Hash is how we hash the key: "string", "int" or "comparable".
+func (c config) Hash() string {
+	switch {
+	case c.Key == "string":
+		return "string"
+	case isInteger(c.Key):
+		return "int"
+	default:
+		return "comparable"
+	}
+}
+
+// KeyExpr and ValueExpr are expressions that convert the int i into a key or
+// value for the tests. They return "" if we don't know how.
+func (c config) KeyExpr() string   { return fromInt(c.Key) }
+func (c config) ValueExpr() string { return fromInt(c.Value) }
+
+// NumTestKeys is the number of keys the tests put in the table. We fill it
+// half full, unless the key type has fewer distinct values than that.
+func (c config) NumTestKeys() int {
+	n := c.Groups * 8 / 2
+	switch c.Key {
+	case "bool":
+		n = min(n, 2)
+	case "int8", "uint8", "byte":
+		n = min(n, 1<<7)
+	case "int16", "uint16":
+		n = min(n, 1<<15)
+	}
+	return n
+}
+
+// NeedsStrconv is true if the tests use strconv.
+func (c config) NeedsStrconv() bool { return c.Key == "string" || c.Value == "string" }
+
+func fromInt(typ string) string {
+	switch {
+	case typ == "string":
+		return "strconv.Itoa(i)"
+	case typ == "bool":
+		return "i%2 == 0"
+	case isInteger(typ), typ == "float32", typ == "float64":
+		return typ + "(i)"
+	}
+	return ""
+}
+
+func isInteger(typ string) bool {
+	switch typ {
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"byte", "rune":
+		return true
+	}
+	return false
+}
+
+func generate(c config) ([]byte, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return execute(tableTemplate, c)
+}
+
+func generateTests(c config) ([]byte, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if c.KeyExpr() == "" {
+		return nil, fmt.Errorf("can't generate tests for key type %s. Use -tests=false", c.Key)
+	}
+	return execute(testTemplate, c)
+}
+
+func execute(t *template.Template, c config) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, c); err != nil {
+		return nil, fmt.Errorf("executing template: %w", err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+var tableTemplate = template.Must(template.New("table").Parse(`// Code generated by swissgen -type {{.Type}} -key {{.Key}} -value {{.Value}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- if ne .Hash "string"}}
+	"hash/maphash"
+{{- end}}
+	"math/bits"
+{{- if eq .Hash "string"}}
+	"unsafe"
+{{- end}}
+)
+
+// {{.Type}} is a Swiss table mapping {{.Key}} to {{.Value}}.
+type {{.Type}} struct {
+	groups [{{.Prefix}}NumGroups]{{.Prefix}}Group
+}
+
+const {{.Prefix}}NumGroups = {{.Groups}}
+
+// New{{.Type}} creates a new, empty {{.Type}}.
+func New{{.Type}}() *{{.Type}} {
+	m := &{{.Type}}{}
+	for i := range m.groups {
+		m.groups[i].ctrl = {{.Prefix}}Ctrl(0x8080_8080_8080_8080)
+	}
+	return m
+}
+
+// Set sets the value for key.
+func (m *{{.Type}}) Set(key {{.Key}}, value {{.Value}}) {
+	h := {{.Prefix}}Hash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101_0101_0101_0101
+
+	const mask = {{.Prefix}}NumGroups - 1
+	offset := h2 & mask
+	for index := uint64(1); ; index++ {
+		g := &m.groups[offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; e.key == key {
+				e.value = value
+				return
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			i := bits.TrailingZeros64(empties) / 8
+			g.entries[i] = {{.Prefix}}Entry{key: key, value: value}
+			g.ctrl.set(i, h1)
+			return
+		}
+		offset = (offset + index) & mask
+	}
+}
+
+// Get returns the value for key, and whether key is present.
+func (m *{{.Type}}) Get(key {{.Key}}) (v {{.Value}}, ok bool) {
+	if m == nil {
+		return v, false
+	}
+	h := {{.Prefix}}Hash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101_0101_0101_0101
+
+	const mask = {{.Prefix}}NumGroups - 1
+	offset := h2 & mask
+	for index := uint64(1); ; index++ {
+		g := &m.groups[offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; e.key == key {
+				return e.value, true
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return v, false
+		}
+		offset = (offset + index) & mask
+	}
+}
+
+type {{.Prefix}}Group struct {
+	ctrl    {{.Prefix}}Ctrl
+	entries [8]{{.Prefix}}Entry
+}
+
+type {{.Prefix}}Entry struct {
+	key   {{.Key}}
+	value {{.Value}}
+}
+
+type {{.Prefix}}Ctrl uint64
+
+func (gc {{.Prefix}}Ctrl) findMatches(h1Expanded uint64) uint64 {
+	matchesAreZero := (uint64(gc) ^ h1Expanded)
+	return ((matchesAreZero - 0x0101_0101_0101_0101) &^ matchesAreZero) & 0x8080_8080_8080_8080
+}
+
+func (gc {{.Prefix}}Ctrl) findEmpty() uint64 {
+	return (uint64(gc) & 0x8080_8080_8080_8080)
+}
+
+func (gc *{{.Prefix}}Ctrl) set(i int, v byte) {
+	shift := uint(i) * 8
+	*gc = {{.Prefix}}Ctrl((uint64(*gc) &^ (0xFF << shift)) | uint64(v)<<shift)
+}
+{{if eq .Hash "string"}}
+func {{.Prefix}}Hash(key string) uint64 {
+	return uint64({{.Prefix}}Memhash(unsafe.Pointer(unsafe.StringData(key)), 0, uintptr(len(key))))
+}
+
+//go:linkname {{.Prefix}}Memhash runtime.memhash
+//go:noescape
+func {{.Prefix}}Memhash(p unsafe.Pointer, seed, s uintptr) uintptr
+{{else if eq .Hash "int"}}
+var {{.Prefix}}Seed = uint64(maphash.Comparable(maphash.MakeSeed(), 0))
+
+// {{.Prefix}}Hash is the finaliser from MurmurHash3.
+func {{.Prefix}}Hash(key {{.Key}}) uint64 {
+	h := uint64(key) ^ {{.Prefix}}Seed
+	h ^= h >> 33
+	h *= 0xff51_afd7_ed55_8ccd
+	h ^= h >> 33
+	h *= 0xc4ce_b9fe_1a85_ec53
+	h ^= h >> 33
+	return h
+}
+{{else}}
+var {{.Prefix}}Seed = maphash.MakeSeed()
+
+func {{.Prefix}}Hash(key {{.Key}}) uint64 {
+	return maphash.Comparable({{.Prefix}}Seed, key)
+}
+{{end}}`))
+
+var testTemplate = template.Must(template.New("test").Parse(`// Code generated by swissgen -type {{.Type}} -key {{.Key}} -value {{.Value}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"reflect"
+{{- if .NeedsStrconv}}
+	"strconv"
+{{- end}}
+	"testing"
+)
+
+func {{.Prefix}}TestKey(i int) {{.Key}} {
+	return {{.KeyExpr}}
+}
+
+func {{.Prefix}}TestValue(i int) (v {{.Value}}) {
+{{- if .ValueExpr}}
+	return {{.ValueExpr}}
+{{- else}}
+	return v
+{{- end}}
+}
+
+func Test{{.Type}}(t *testing.T) {
+	m := New{{.Type}}()
+	if _, ok := m.Get({{.Prefix}}TestKey(0)); ok {
+		t.Fatalf("expected missing key to return ok == false")
+	}
+
+	const numKeys = {{.NumTestKeys}}
+	for i := range numKeys {
+		m.Set({{.Prefix}}TestKey(i), {{.Prefix}}TestValue(i))
+	}
+	// Overwrite a key.
+	m.Set({{.Prefix}}TestKey(0), {{.Prefix}}TestValue(1))
+
+	for i := range numKeys {
+		want := {{.Prefix}}TestValue(i)
+		if i == 0 {
+			want = {{.Prefix}}TestValue(1)
+		}
+		val, ok := m.Get({{.Prefix}}TestKey(i))
+		if !ok {
+			t.Fatalf("expected key %v to be present", {{.Prefix}}TestKey(i))
+		}
+		if !reflect.DeepEqual(val, want) {
+			t.Fatalf("expected key %v to have value %v, got %v", {{.Prefix}}TestKey(i), want, val)
+		}
+	}
+}
+`))
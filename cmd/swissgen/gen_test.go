@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	good := config{Type: "StringInt", Key: "string", Value: "int", Package: "p", Groups: 16}
+	if err := good.validate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for _, c := range []config{
+		{Type: "", Key: "string", Value: "int", Package: "p", Groups: 16},
+		{Type: "StringInt", Key: "", Value: "int", Package: "p", Groups: 16},
+		{Type: "StringInt", Key: "string", Value: "", Package: "p", Groups: 16},
+		{Type: "StringInt", Key: "string", Value: "int", Package: "", Groups: 16},
+		{Type: "StringInt", Key: "string", Value: "int", Package: "p", Groups: 12},
+	} {
+		if err := c.validate(); err == nil {
+			t.Errorf("expected an error for %#v", c)
+		}
+	}
+}
+
+// TestGenerated generates a selection of tables into a scratch module and runs
+// the generated tests there.
+func TestGenerated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs generated code")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go tool")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module gentest\n\ngo 1.25.0\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		config
+		tests bool
+	}{
+		{config{Type: "StringInt", Key: "string", Value: "int"}, true},
+		{config{Type: "Uint64String", Key: "uint64", Value: "string"}, true},
+		{config{Type: "Int8Bool", Key: "int8", Value: "bool"}, true},
+		{config{Type: "BoolInt", Key: "bool", Value: "int"}, true},
+		{config{Type: "Float64Slice", Key: "float64", Value: "[]byte"}, true},
+		{config{Type: "ArrayInt", Key: "[4]byte", Value: "int"}, false},
+	} {
+		c.Package = "gentest"
+		c.Groups = 256
+		if err := run(c.config, filepath.Join(dir, strings.ToLower(c.Type)+".go"), c.tests); err != nil {
+			t.Fatalf("generating %s: %v", c.Type, err)
+		}
+	}
+
+	for _, args := range [][]string{{"vet", "."}, {"test", "."}} {
+		cmd := exec.Command(goTool, args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("go %s failed: %v\n%s", args[0], err, out)
+		}
+	}
+}
+
+func TestNoTestsForUnknownKeys(t *testing.T) {
+	c := config{Type: "ArrayInt", Key: "[4]byte", Value: "int", Package: "p", Groups: 16}
+	if _, err := generateTests(c); err == nil {
+		t.Fatalf("expected an error generating tests for an array key")
+	}
+}
@@ -0,0 +1,71 @@
+// Command swissgen writes a concrete, non-generic Swiss table for a given key
+// and value type, in the style of hashblog.SwissConcrete.
+//
+// SwissConcrete is faster than SwissTable[string, int] because the compiler
+// knows exactly what it's dealing with, and because it can pick a hash function
+// suited to the key. But writing one by hand for every combination of key and
+// value gets old quickly. swissgen is meant to be used with go:generate.
+//
+//	//go:generate go run github.com/philpearl/hashblog/cmd/swissgen -type StringToID -key string -value uint32
+//
+// This writes stringtoid.go, containing StringToID and NewStringToID, and
+// stringtoid_test.go containing tests for them.
+//
+// The hash function depends on the key type. String keys use the runtime's
+// memhash, integer keys use a multiply-xorshift mixer, and anything else uses
+// maphash.Comparable.
+//
+// Key and value types must either be predeclared or be declared in the package
+// the table is generated into: swissgen doesn't add imports for them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	var c config
+	flag.StringVar(&c.Type, "type", "", "name of the table type to generate")
+	flag.StringVar(&c.Key, "key", "", "key type")
+	flag.StringVar(&c.Value, "value", "", "value type")
+	flag.StringVar(&c.Package, "package", os.Getenv("GOPACKAGE"), "package name for the generated code (defaults to $GOPACKAGE)")
+	flag.IntVar(&c.Groups, "groups", 4096, "number of groups of 8 entries in the table. Must be a power of two")
+	output := flag.String("o", "", "output file (defaults to the lower-cased type name with .go)")
+	tests := flag.Bool("tests", true, "also write a _test.go file with tests for the table")
+	flag.Parse()
+
+	if err := run(c, *output, *tests); err != nil {
+		fmt.Fprintf(os.Stderr, "swissgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(c config, output string, tests bool) error {
+	if output == "" {
+		output = strings.ToLower(c.Type) + ".go"
+	}
+
+	// Generate everything before writing anything, so we don't leave a table
+	// without its tests.
+	src, err := generate(c)
+	if err != nil {
+		return err
+	}
+	var testSrc []byte
+	if tests {
+		if testSrc, err = generateTests(c); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(output, src, 0o666); err != nil {
+		return err
+	}
+	if !tests {
+		return nil
+	}
+	return os.WriteFile(strings.TrimSuffix(output, ".go")+"_test.go", testSrc, 0o666)
+}
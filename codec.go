@@ -0,0 +1,127 @@
+package hashblog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Codec encodes and decodes the keys or values of a SwissTable for
+// MarshalBinary and UnmarshalBinary. See SwissTable.SetCodecs.
+type Codec[T any] interface {
+	// Append appends the encoding of v to b.
+	Append(b []byte, v T) ([]byte, error)
+	// Decode decodes a value from the start of b. It returns the value and the
+	// number of bytes it used. The value must not refer to b's memory, as b
+	// may be reused.
+	Decode(b []byte) (v T, n int, err error)
+}
+
+// errShortBuffer is returned by the codecs when the data ends part way
+// through a value.
+var errShortBuffer = errors.New("data ends part way through a value")
+
+// StringCodec encodes strings as their length as a uvarint followed by their
+// bytes.
+type StringCodec struct{}
+
+func (StringCodec) Append(b []byte, v string) ([]byte, error) {
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...), nil
+}
+
+func (StringCodec) Decode(b []byte) (string, int, error) {
+	l, n := binary.Uvarint(b)
+	if n <= 0 || l > uint64(len(b)-n) {
+		return "", 0, errShortBuffer
+	}
+	return string(b[n : n+int(l)]), n + int(l), nil
+}
+
+// IntCodec encodes signed integers as varints.
+type IntCodec[T ~int | ~int8 | ~int16 | ~int32 | ~int64] struct{}
+
+func (IntCodec[T]) Append(b []byte, v T) ([]byte, error) {
+	return binary.AppendVarint(b, int64(v)), nil
+}
+
+func (IntCodec[T]) Decode(b []byte) (T, int, error) {
+	v, n := binary.Varint(b)
+	if n <= 0 {
+		return 0, 0, errShortBuffer
+	}
+	if int64(T(v)) != v {
+		return 0, 0, fmt.Errorf("%d overflows %T", v, T(0))
+	}
+	return T(v), n, nil
+}
+
+// UintCodec encodes unsigned integers as uvarints.
+type UintCodec[T ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr] struct{}
+
+func (UintCodec[T]) Append(b []byte, v T) ([]byte, error) {
+	return binary.AppendUvarint(b, uint64(v)), nil
+}
+
+func (UintCodec[T]) Decode(b []byte) (T, int, error) {
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, 0, errShortBuffer
+	}
+	if uint64(T(v)) != v {
+		return 0, 0, fmt.Errorf("%d overflows %T", v, T(0))
+	}
+	return T(v), n, nil
+}
+
+// Float64Codec encodes float64s as their 8 bytes, little-endian.
+type Float64Codec struct{}
+
+func (Float64Codec) Append(b []byte, v float64) ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(v)), nil
+}
+
+func (Float64Codec) Decode(b []byte) (float64, int, error) {
+	if len(b) < 8 {
+		return 0, 0, errShortBuffer
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), 8, nil
+}
+
+// defaultCodec returns the codec SwissTable uses for T if SetCodecs isn't
+// called, or nil if there isn't one.
+func defaultCodec[T any]() Codec[T] {
+	var c any
+	switch any(*new(T)).(type) {
+	case string:
+		c = StringCodec{}
+	case int:
+		c = IntCodec[int]{}
+	case int8:
+		c = IntCodec[int8]{}
+	case int16:
+		c = IntCodec[int16]{}
+	case int32:
+		c = IntCodec[int32]{}
+	case int64:
+		c = IntCodec[int64]{}
+	case uint:
+		c = UintCodec[uint]{}
+	case uint8:
+		c = UintCodec[uint8]{}
+	case uint16:
+		c = UintCodec[uint16]{}
+	case uint32:
+		c = UintCodec[uint32]{}
+	case uint64:
+		c = UintCodec[uint64]{}
+	case uintptr:
+		c = UintCodec[uintptr]{}
+	case float64:
+		c = Float64Codec{}
+	default:
+		return nil
+	}
+	return c.(Codec[T])
+}
@@ -0,0 +1,42 @@
+package hashblog
+
+import "expvar"
+
+// Counters counts what a table has been doing. Tables only keep counts when
+// built with the counters build tag. Otherwise the counting compiles away to
+// nothing, and Counters are always zero.
+type Counters struct {
+	// Gets is the number of lookups. Each is either a hit or a miss.
+	Gets   uint64
+	Hits   uint64
+	Misses uint64
+	// Sets is the number of calls to Set. Each either inserts a new key or
+	// overwrites the value of an existing one.
+	Sets       uint64
+	Inserts    uint64
+	Overwrites uint64
+	// GroupsProbed is the number of groups visited by lookups and sets.
+	GroupsProbed uint64
+	// KeyComparisons is the number of times we compared a key with one in the
+	// table because the control byte matched.
+	KeyComparisons uint64
+	// FalsePositives is the number of those comparisons where the keys
+	// differed.
+	FalsePositives uint64
+}
+
+// Counters returns the table's counters.
+func (m *GroupTableCtrl[K, V]) Counters() Counters { return m.counters.snapshot() }
+
+// Counters returns the table's counters.
+func (m *SwissTable[K, V]) Counters() Counters { return m.counters.snapshot() }
+
+// Counters returns the table's counters.
+func (m *SwissConcrete) Counters() Counters { return m.counters.snapshot() }
+
+// PublishCounters publishes a table's counters as the expvar variable name.
+// Like expvar.Publish it panics if name is already in use. Without the counters
+// build tag the published counters are always zero.
+func PublishCounters(name string, m interface{ Counters() Counters }) {
+	expvar.Publish(name, expvar.Func(func() any { return m.Counters() }))
+}
@@ -0,0 +1,47 @@
+//go:build counters
+
+package hashblog
+
+import "sync/atomic"
+
+// CountersEnabled is true if the tables are built to keep Counters.
+const CountersEnabled = true
+
+// opCounters counts operations on a table. The tables aren't safe for
+// concurrent use, but the counters may be read by expvar from another
+// goroutine, so we use atomics.
+type opCounters struct {
+	gets           atomic.Uint64
+	hits           atomic.Uint64
+	misses         atomic.Uint64
+	sets           atomic.Uint64
+	inserts        atomic.Uint64
+	overwrites     atomic.Uint64
+	groupsProbed   atomic.Uint64
+	keyComparisons atomic.Uint64
+	falsePositives atomic.Uint64
+}
+
+func (c *opCounters) get()           { c.gets.Add(1) }
+func (c *opCounters) hit()           { c.hits.Add(1) }
+func (c *opCounters) miss()          { c.misses.Add(1) }
+func (c *opCounters) set()           { c.sets.Add(1) }
+func (c *opCounters) insert()        { c.inserts.Add(1) }
+func (c *opCounters) overwrite()     { c.overwrites.Add(1) }
+func (c *opCounters) probeGroup()    { c.groupsProbed.Add(1) }
+func (c *opCounters) compareKey()    { c.keyComparisons.Add(1) }
+func (c *opCounters) falsePositive() { c.falsePositives.Add(1) }
+
+func (c *opCounters) snapshot() Counters {
+	return Counters{
+		Gets:           c.gets.Load(),
+		Hits:           c.hits.Load(),
+		Misses:         c.misses.Load(),
+		Sets:           c.sets.Load(),
+		Inserts:        c.inserts.Load(),
+		Overwrites:     c.overwrites.Load(),
+		GroupsProbed:   c.groupsProbed.Load(),
+		KeyComparisons: c.keyComparisons.Load(),
+		FalsePositives: c.falsePositives.Load(),
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !counters
+
+package hashblog
+
+// CountersEnabled is true if the tables are built to keep Counters.
+const CountersEnabled = false
+
+// opCounters takes no space and does nothing unless we're built with the
+// counters build tag. See counters_enabled.go.
+type opCounters struct{}
+
+func (c *opCounters) get()           {}
+func (c *opCounters) hit()           {}
+func (c *opCounters) miss()          {}
+func (c *opCounters) set()           {}
+func (c *opCounters) insert()        {}
+func (c *opCounters) overwrite()     {}
+func (c *opCounters) probeGroup()    {}
+func (c *opCounters) compareKey()    {}
+func (c *opCounters) falsePositive() {}
+
+func (c *opCounters) snapshot() Counters { return Counters{} }
@@ -0,0 +1,71 @@
+//go:build counters
+
+package hashblog_test
+
+import (
+	"expvar"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+func TestCounters(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		m    interface {
+			mapper
+			counted
+		}
+	}{
+		{"GroupTableCtrl", hashblog.NewGroupTableCtrl[string, int]()},
+		{"SwissTable", hashblog.NewSwissTable[string, int]()},
+		{"SwissConcrete", hashblog.NewSwissConcrete()},
+		{"DoubleSwiss", hashblog.NewDoubleSwiss()},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m := test.m
+			for i := range 1000 {
+				m.Set(strconv.Itoa(i), i)
+			}
+			for i := range 100 {
+				m.Set(strconv.Itoa(i), i+1)
+			}
+			for i := range 2000 {
+				m.Get(strconv.Itoa(i))
+			}
+
+			c := m.Counters()
+			if c.Sets != 1100 || c.Inserts != 1000 || c.Overwrites != 100 {
+				t.Errorf("expected 1100 sets, 1000 inserts and 100 overwrites, got %d, %d and %d", c.Sets, c.Inserts, c.Overwrites)
+			}
+			if c.Gets != 2000 || c.Hits != 1000 || c.Misses != 1000 {
+				t.Errorf("expected 2000 gets, 1000 hits and 1000 misses, got %d, %d and %d", c.Gets, c.Hits, c.Misses)
+			}
+			// Every operation visits at least one group.
+			if c.GroupsProbed < c.Sets+c.Gets {
+				t.Errorf("expected at least %d groups probed, got %d", c.Sets+c.Gets, c.GroupsProbed)
+			}
+			// Every overwrite and hit needs a key comparison that succeeds.
+			if matched := c.KeyComparisons - c.FalsePositives; matched != c.Overwrites+c.Hits {
+				t.Errorf("expected %d matching key comparisons, got %d", c.Overwrites+c.Hits, matched)
+			}
+		})
+	}
+}
+
+func TestPublishCounters(t *testing.T) {
+	m := hashblog.NewSwissTable[string, int]()
+	hashblog.PublishCounters("hashblog_test_swiss", m)
+	m.Set("a", 1)
+	m.Get("a")
+
+	v := expvar.Get("hashblog_test_swiss")
+	if v == nil {
+		t.Fatal("counters not published")
+	}
+	if s := v.String(); !strings.Contains(s, `"Sets":1`) || !strings.Contains(s, `"Hits":1`) {
+		t.Fatalf("unexpected published counters %s", s)
+	}
+}
@@ -0,0 +1,84 @@
+package hashblog
+
+import "unsafe"
+
+// DictionaryMaxStrings is the most strings a Dictionary can hold. Like the
+// tables it's built on it can't grow, and we keep an eighth of the table free
+// so probe sequences stay short.
+const DictionaryMaxStrings = simpleTableSize * 7 / 8
+
+// Dictionary gives each distinct string it sees a dense ID, counting up from
+// zero, so a column of strings can be stored as a column of IDs.
+//
+// The strings are copied into an arena, as in SwissArena, and a SwissConcrete
+// maps each string to its ID. The keys in the SwissConcrete are the copies in
+// the arena, so each string is only stored once, and it takes one allocation
+// per 64KB of strings rather than one per string.
+//
+// We don't keep the strings in one contiguous []byte with an offset for each
+// ID, though String would find them just as easily. The keys in the
+// SwissConcrete, and the strings String has returned, point into the arena.
+// Growing a single slice copies it, and those strings would keep every old
+// copy alive, so the Dictionary would hold about twice the bytes its strings
+// need. The arena's chunks never move, so each string is stored once.
+//
+// A Dictionary never forgets a string, so an ID is valid for as long as the
+// Dictionary is.
+type Dictionary struct {
+	ids   *SwissConcrete
+	arena stringArena
+	// strings holds the location of each string in the arena, indexed by ID.
+	strings []arenaRef
+}
+
+func NewDictionary() *Dictionary {
+	return &Dictionary{ids: NewSwissConcrete()}
+}
+
+// Intern returns the ID for s, giving it the next ID if it doesn't already
+// have one. isNew is true if it didn't. Intern panics if s is new and the
+// Dictionary already holds DictionaryMaxStrings strings.
+func (d *Dictionary) Intern(s string) (id uint32, isNew bool) {
+	if id, ok := d.ids.Get(s); ok {
+		return uint32(id), false
+	}
+	if len(d.strings) == DictionaryMaxStrings {
+		panic("hashblog: Dictionary is full")
+	}
+	ref := d.arena.add(s)
+	id = uint32(len(d.strings))
+	d.strings = append(d.strings, ref)
+	d.ids.Set(d.arena.get(ref), int(id))
+	return id, true
+}
+
+// InternBytes is Intern for a []byte. It only copies b if it is new, and
+// doesn't keep b.
+func (d *Dictionary) InternBytes(b []byte) (id uint32, isNew bool) {
+	// It's safe to treat b as a string: Intern copies it into the arena
+	// before keeping it.
+	return d.Intern(unsafe.String(unsafe.SliceData(b), len(b)))
+}
+
+// Lookup returns the ID for s, if it has one.
+func (d *Dictionary) Lookup(s string) (id uint32, ok bool) {
+	v, ok := d.ids.Get(s)
+	return uint32(v), ok
+}
+
+// LookupBytes is Lookup for a []byte. It doesn't allocate.
+func (d *Dictionary) LookupBytes(b []byte) (id uint32, ok bool) {
+	// Converting b to a string would usually copy it. Get doesn't keep its
+	// key, so we can look at b's bytes directly.
+	return d.Lookup(unsafe.String(unsafe.SliceData(b), len(b)))
+}
+
+// String returns the string with the given ID. It shares memory with the
+// arena, so it doesn't allocate. String panics if no string has the ID.
+func (d *Dictionary) String(id uint32) string {
+	return d.arena.get(d.strings[id])
+}
+
+// Len returns the number of strings in the dictionary. They have IDs 0 to
+// Len()-1.
+func (d *Dictionary) Len() int { return len(d.strings) }
@@ -0,0 +1,130 @@
+package hashblog_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+func TestDictionary(t *testing.T) {
+	d := hashblog.NewDictionary()
+	// Include an empty string and some longer than the 32 bytes Go will
+	// convert from []byte on the stack.
+	words := []string{"", "a", "b", strings.Repeat("long", 20)}
+	for i := range 20000 {
+		words = append(words, "word"+strconv.Itoa(i))
+	}
+
+	for i, w := range words {
+		id, isNew := d.Intern(w)
+		if !isNew || id != uint32(i) {
+			t.Fatalf("Intern(%q) = %d, %t. Expected %d, true", w, id, isNew, i)
+		}
+	}
+	if d.Len() != len(words) {
+		t.Fatalf("expected %d strings, got %d", len(words), d.Len())
+	}
+
+	for i, w := range words {
+		if id, isNew := d.Intern(w); isNew || id != uint32(i) {
+			t.Fatalf("Intern(%q) again = %d, %t. Expected %d, false", w, id, isNew, i)
+		}
+		if id, ok := d.Lookup(w); !ok || id != uint32(i) {
+			t.Fatalf("Lookup(%q) = %d, %t", w, id, ok)
+		}
+		if id, ok := d.LookupBytes([]byte(w)); !ok || id != uint32(i) {
+			t.Fatalf("LookupBytes(%q) = %d, %t", w, id, ok)
+		}
+		if s := d.String(uint32(i)); s != w {
+			t.Fatalf("String(%d) = %q, expected %q", i, s, w)
+		}
+	}
+	if _, ok := d.Lookup("missing"); ok {
+		t.Fatalf("found a string that was never interned")
+	}
+	if d.Len() != len(words) {
+		t.Fatalf("looking strings up changed the number of strings to %d", d.Len())
+	}
+}
+
+func TestDictionaryInternBytes(t *testing.T) {
+	d := hashblog.NewDictionary()
+	buf := []byte("hello")
+	id, isNew := d.InternBytes(buf)
+	if !isNew || id != 0 {
+		t.Fatalf("InternBytes = %d, %t", id, isNew)
+	}
+
+	// The dictionary has its own copy, so reusing buf doesn't change it.
+	copy(buf, "jello")
+	if s := d.String(0); s != "hello" {
+		t.Fatalf("expected hello, got %q", s)
+	}
+	if _, ok := d.Lookup("jello"); ok {
+		t.Fatalf("found jello")
+	}
+	if id, isNew := d.InternBytes([]byte("hello")); isNew || id != 0 {
+		t.Fatalf("InternBytes(hello) again = %d, %t", id, isNew)
+	}
+}
+
+func TestDictionaryAllocs(t *testing.T) {
+	d := hashblog.NewDictionary()
+	short := []byte("short")
+	long := []byte(strings.Repeat("a much longer key ", 10))
+	d.InternBytes(short)
+	d.InternBytes(long)
+	missing := []byte(strings.Repeat("missing", 10))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		d.LookupBytes(short)
+		d.LookupBytes(long)
+		d.LookupBytes(missing)
+		d.InternBytes(long)
+		_ = d.String(1)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestDictionaryFull(t *testing.T) {
+	d := hashblog.NewDictionary()
+	for i := range hashblog.DictionaryMaxStrings {
+		d.Intern(strconv.Itoa(i))
+	}
+	// Strings already in the dictionary are fine.
+	if id, isNew := d.Intern("0"); isNew || id != 0 {
+		t.Fatalf("Intern(0) = %d, %t", id, isNew)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic")
+		}
+	}()
+	d.Intern("one too many")
+}
+
+func BenchmarkDictionaryLookupBytes(b *testing.B) {
+	keys := make([][]byte, 1<<14)
+	d := hashblog.NewDictionary()
+	m := make(map[string]uint32, len(keys))
+	for i := range keys {
+		keys[i] = []byte("key" + strconv.Itoa(i))
+		id, _ := d.InternBytes(keys[i])
+		m[string(keys[i])] = id
+	}
+
+	b.Run("i=Dictionary", func(b *testing.B) {
+		for i := range b.N {
+			d.LookupBytes(keys[i&(len(keys)-1)])
+		}
+	})
+	b.Run("i=map", func(b *testing.B) {
+		for i := range b.N {
+			_ = m[string(keys[i&(len(keys)-1)])]
+		}
+	})
+}
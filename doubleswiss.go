@@ -3,6 +3,7 @@
 package hashblog
 
 import (
+	"io"
 	"math/bits"
 	"simd/archsimd"
 )
@@ -23,7 +24,11 @@ const (
 // matching control bytes and empty slots, reducing the number of operations
 // needed to find these.
 type DoubleSwiss struct {
-	groups [doubleSwissTableSize]swissGroup
+	counters opCounters
+	groups   [doubleSwissTableSize]swissGroup
+	len      int
+	// tombstones is the number of deleted markers. See tombstones.
+	tombstones tombstones
 }
 
 func NewDoubleSwiss() *DoubleSwiss {
@@ -35,6 +40,7 @@ func NewDoubleSwiss() *DoubleSwiss {
 }
 
 func (m *DoubleSwiss) Set(key string, value int) {
+	m.counters.set()
 	h := concreteHash(key)
 
 	h1 := byte(h & 0x7F)
@@ -44,7 +50,13 @@ func (m *DoubleSwiss) Set(key string, value int) {
 	// compare against all control bytes in a group simultaneously.
 	h1Expanded := archsimd.BroadcastUint8x16(h1)
 
+	// The first empty or deleted slot we pass. If the key isn't in the table
+	// this is where it goes.
+	var slotGroup *swissGroup
+	var slot int
+
 	for seq := makeProbeSeq(h2, hashValue(doubleSwissTableSize-1)); ; seq = seq.next() {
+		m.counters.probeGroup()
 		g := &m.groups[seq.offset]
 		// Find possible matches for this entry in the group. findMatches
 		// returns a bitmask where each byte with a matching control byte has
@@ -52,20 +64,36 @@ func (m *DoubleSwiss) Set(key string, value int) {
 		matches := g.ctrl.findMatches(h1Expanded)
 		for matches != 0 {
 			i := matches.first()
+			m.counters.compareKey()
 			if e := &g.entries[i]; e.key == key {
+				m.counters.overwrite()
 				e.value = value
 				return
 			}
+			m.counters.falsePositive()
 			// Clear the lowest set bit and continue
 			matches &= matches - 1
 		}
+		if slotGroup == nil {
+			if avail := g.ctrl.findEmptyOrDeleted(); avail != 0 {
+				slotGroup, slot = g, avail.first()
+			}
+		}
 		// Check for empty slot in group. This returns a bitmask where each
 		// byte that is empty has its high bit set.
 		if empties := g.ctrl.findEmpty(); empties != 0 {
-			i := empties.first()
 			// Empty slot - this means the key is not present in the table
-			g.entries[i] = concreteEntry{key: key, value: value}
-			g.ctrl[i] = h1
+			m.counters.insert()
+			if m.tombstones.tooMany(m.len) {
+				// Rehashing moves everything, including the slot we found.
+				m.rehash()
+				m.insert(key, value, h)
+				return
+			}
+			m.tombstones.reused(slotGroup.ctrl[slot])
+			slotGroup.entries[slot] = concreteEntry{key: key, value: value}
+			slotGroup.ctrl[slot] = h1
+			m.len++
 			return
 		}
 	}
@@ -75,8 +103,11 @@ func (m *DoubleSwiss) Get(key string) (v int, ok bool) {
 	if m == nil {
 		return v, false
 	}
-	h := concreteHash(key)
+	return m.get(key, concreteHash(key))
+}
 
+// get looks up key, given its hash h.
+func (m *DoubleSwiss) get(key string, h hashValue) (v int, ok bool) {
 	h1 := byte(h & 0x7F)
 	h2 := (h >> 7)
 
@@ -84,7 +115,9 @@ func (m *DoubleSwiss) Get(key string) (v int, ok bool) {
 	// compare against all control bytes in a group simultaneously.
 	h1Expanded := archsimd.BroadcastUint8x16(h1)
 
+	m.counters.get()
 	for seq := makeProbeSeq(h2, hashValue(doubleSwissTableSize-1)); ; seq = seq.next() {
+		m.counters.probeGroup()
 		g := &m.groups[seq.offset]
 		// Find possible matches for this entry in the group. findMatches
 		// returns a bitmask where each byte with a matching control byte has
@@ -92,9 +125,12 @@ func (m *DoubleSwiss) Get(key string) (v int, ok bool) {
 		matches := g.ctrl.findMatches(h1Expanded)
 		for matches != 0 {
 			i := matches.first()
+			m.counters.compareKey()
 			if e := &g.entries[i]; e.key == key {
+				m.counters.hit()
 				return e.value, true
 			}
+			m.counters.falsePositive()
 			// Clear the lowest set bit and continue
 			matches &= matches - 1
 		}
@@ -102,11 +138,170 @@ func (m *DoubleSwiss) Get(key string) (v int, ok bool) {
 		// is not present in the table.
 		empties := g.ctrl.findEmpty()
 		if empties != 0 {
+			m.counters.miss()
 			return v, false
 		}
 	}
 }
 
+// Delete removes key from the table. See SwissTable.Delete for how this works.
+func (m *DoubleSwiss) Delete(key string) {
+	h := concreteHash(key)
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := archsimd.BroadcastUint8x16(h1)
+
+	for seq := makeProbeSeq(h2, hashValue(doubleSwissTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := matches.first()
+			if e := &g.entries[i]; e.key == key {
+				*e = concreteEntry{}
+				m.tombstones.deleted(g.ctrl.delete(i))
+				m.len--
+				return
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return
+		}
+	}
+}
+
+// Clear removes everything from the table.
+func (m *DoubleSwiss) Clear() {
+	for i := range m.groups {
+		m.groups[i] = swissGroup{ctrl: swissCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}}
+	}
+	m.len, m.tombstones = 0, 0
+}
+
+// rehash removes the deleted markers by taking every entry out and inserting
+// it again. See tombstones.
+func (m *DoubleSwiss) rehash() {
+	all := make([]concreteEntry, 0, m.len)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl < 0x80 {
+				all = append(all, g.entries[i])
+			}
+		}
+	}
+	m.Clear()
+	for _, e := range all {
+		m.insert(e.key, e.value, concreteHash(e.key))
+	}
+}
+
+// insert adds key, which has hash h and isn't already in the table, to the
+// first empty slot on its probe sequence.
+func (m *DoubleSwiss) insert(key string, value int, h hashValue) {
+	for seq := makeProbeSeq(h>>7, hashValue(doubleSwissTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			i := empties.first()
+			g.entries[i] = concreteEntry{key: key, value: value}
+			g.ctrl[i] = byte(h & 0x7F)
+			m.len++
+			return
+		}
+	}
+}
+
+// GetBatch looks up each of keys, setting out[i] to the value for keys[i] and
+// found[i] to whether keys[i] is present. out and found must be at least as
+// long as keys.
+//
+// See SwissTable.GetBatch for how this works.
+func (m *DoubleSwiss) GetBatch(keys []string, out []int, found []bool) {
+	if m == nil {
+		clear(out[:len(keys)])
+		clear(found[:len(keys)])
+		return
+	}
+	var hashes [batchSize]hashValue
+	for len(keys) > 0 {
+		n := min(len(keys), batchSize)
+		for i, key := range keys[:n] {
+			hashes[i] = concreteHash(key)
+		}
+		touchGroups(m.groups[:], hashes[:n])
+		for i, key := range keys[:n] {
+			out[i], found[i] = m.get(key, hashes[i])
+		}
+		keys, out, found = keys[n:], out[n:], found[n:]
+	}
+}
+
+// Counters returns the table's counters.
+func (m *DoubleSwiss) Counters() Counters { return m.counters.snapshot() }
+
+// Stats walks the table and returns statistics about it.
+func (m *DoubleSwiss) Stats() Stats {
+	s := newStats(simpleTableSize, doubleSwissGroupSize)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		var used int
+		for i, ctrl := range g.ctrl {
+			switch {
+			case ctrl == ctrlDeleted:
+				s.Tombstones++
+			case ctrl < 0x80:
+				used++
+				s.addProbe(probeLength(concreteHash(g.entries[i].key)>>7, doubleSwissTableSize-1, gi))
+			}
+		}
+		s.GroupOccupancy[used]++
+	}
+	s.finish()
+	return s
+}
+
+// Visualize draws the table's control bytes. See GroupTableCtrl.Visualize.
+func (m *DoubleSwiss) Visualize(w io.Writer, format VizFormat) error {
+	slots := make([]vizSlot, 0, simpleTableSize)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i, ctrl := range g.ctrl {
+			s := vizSlot{ctrl: ctrl}
+			if ctrl < 0x80 {
+				s.probe = probeLength(concreteHash(g.entries[i].key)>>7, doubleSwissTableSize-1, gi)
+			}
+			slots = append(slots, s)
+		}
+	}
+	stats := m.Stats()
+	return visualize(w, format, "DoubleSwiss", doubleSwissGroupSize, slots, &stats)
+}
+
+// Trace looks up key, recording each group visited.
+func (m *DoubleSwiss) Trace(key string) LookupTrace {
+	h := concreteHash(key)
+	t := newTrace("DoubleSwiss", key, h, "group")
+	h1 := byte(h & 0x7F)
+	t.H1, t.H2 = h1, uint64(h>>7)
+	h1Expanded := archsimd.BroadcastUint8x16(h1)
+	for seq := makeProbeSeq(h>>7, hashValue(doubleSwissTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches, empties := g.ctrl.findMatches(h1Expanded), g.ctrl.findEmpty()
+		t.Steps = append(t.Steps, TraceStep{Index: int(seq.offset), Ctrl: ctrlString(g.ctrl[:]), Matches: uint64(matches), Empty: uint64(empties)})
+		for matches != 0 {
+			i := matches.first()
+			if t.compare(i, g.entries[i].key == key); t.Found {
+				return t
+			}
+			matches &= matches - 1
+		}
+		if empties != 0 {
+			return t
+		}
+	}
+}
+
 type swissGroup struct {
 	ctrl    swissCtrl
 	entries [doubleSwissGroupSize]concreteEntry
@@ -129,3 +324,70 @@ var emptyMask = archsimd.BroadcastUint8x16(0x80)
 func (gc *swissCtrl) findEmpty() matchType {
 	return matchType(archsimd.LoadUint8x16((*[doubleSwissGroupSize]uint8)(gc)).Equal(emptyMask).ToBits())
 }
+
+// findEmptyOrDeleted finds slots whose control byte has the top bit set.
+// Control bytes for full slots are at most 0x7F, so that's every control byte
+// that's at least 0x80.
+func (gc *swissCtrl) findEmptyOrDeleted() matchType {
+	return matchType(archsimd.LoadUint8x16((*[doubleSwissGroupSize]uint8)(gc)).GreaterEqual(emptyMask).ToBits())
+}
+
+// delete marks slot i as deleted, or as empty if the group has an empty slot
+// already. It reports whether it left a deleted marker.
+func (gc *swissCtrl) delete(i int) (marked bool) {
+	if gc.findEmpty() != 0 {
+		gc[i] = 0x80
+		return false
+	}
+	gc[i] = ctrlDeleted
+	return true
+}
+
+// MarshalBinary encodes the table. See SwissTable.MarshalBinary.
+func (m *DoubleSwiss) MarshalBinary() ([]byte, error) {
+	return m.AppendBinary(nil)
+}
+
+// AppendBinary appends the encoding of the table to b.
+func (m *DoubleSwiss) AppendBinary(b []byte) ([]byte, error) {
+	b = appendBinaryHeader(b, kindDoubleSwiss, m.len)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl >= 0x80 {
+				continue
+			}
+			b, _ = StringCodec{}.Append(b, g.entries[i].key)
+			b, _ = IntCodec[int]{}.Append(b, g.entries[i].value)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary replaces the contents of the table with the table encoded in
+// data. It can also decode a SwissConcrete. See SwissTable.UnmarshalBinary.
+func (m *DoubleSwiss) UnmarshalBinary(data []byte) error {
+	m.Clear()
+	d, err := newTableDecoder(data, kindDoubleSwiss, kindSwissConcrete)
+	if err != nil {
+		return err
+	}
+
+	err = d.each(func() error {
+		key, err := decode(d, Codec[string](StringCodec{}))
+		if err != nil {
+			return err
+		}
+		value, err := decode(d, Codec[int](IntCodec[int]{}))
+		if err != nil {
+			return err
+		}
+		m.Set(key, value)
+		return nil
+	})
+	if err != nil {
+		m.Clear()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+//go:build weakhash
+
+package hashblog
+
+// SetWeakHash makes every table replace each hash h with weak(h), until the
+// returned function is called. Tables must be created and used entirely between
+// the two calls, as the keys in a table won't be found with a different hash.
+func SetWeakHash(weak func(h uint64) uint64) (restore func()) {
+	testHash = func(h hashValue) hashValue { return hashValue(weak(uint64(h))) }
+	return func() { testHash = nil }
+}
+
+// Weak hashes for use with SetWeakHash. Remember the bottom 7 bits of the hash
+// are h1, which goes in the control bytes, and the rest is h2, which picks the
+// group the probe sequence starts at.
+var (
+	// ConstantHash gives every key the same hash, so every key starts in the
+	// same group, and every control byte matches.
+	ConstantHash = func(h uint64) uint64 { return 0x2A5 }
+
+	// LowEntropyHash keeps just 10 bits of the hash, so there are only 8
+	// starting groups and 128 values of h1.
+	LowEntropyHash = func(h uint64) uint64 { return h & 0x3FF }
+
+	// SameH1Hash sets h1 to the same value for every key, but leaves h2
+	// alone. Keys are spread across the table, but every control byte in use
+	// matches every key.
+	SameH1Hash = func(h uint64) uint64 { return h&^0x7F | 0x25 }
+
+	// SameGroupHash starts every key's probe sequence in the same group, but
+	// leaves h1 alone.
+	SameGroupHash = func(h uint64) uint64 { return h & 0x7F }
+)
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package hashblog_test
+
+import "testing"
+
+// platformFuzzTables returns the tables FuzzOps can only run on this platform.
+// SwissOffHeap needs mmap, so there are none here.
+func platformFuzzTables(t *testing.T) []fuzzTable {
+	return nil
+}
@@ -0,0 +1,191 @@
+package hashblog_test
+
+import (
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+type deleter interface {
+	Delete(key string)
+}
+
+type clearer interface {
+	Clear()
+}
+
+// Operations for FuzzOps. Each operation is encoded as 3 bytes: the operation
+// and a 16 bit little-endian argument.
+const (
+	// opSet sets key arg to value arg.
+	opSet = iota
+	// opGet gets key arg.
+	opGet
+	// opDelete deletes key arg.
+	opDelete
+	// opClear clears the table.
+	opClear
+	// opFill sets keys 0 to arg.
+	opFill
+	// opDeleteEvery deletes every arg'th key.
+	opDeleteEvery
+	numOps
+)
+
+// fuzzKeySpace is the number of distinct keys FuzzOps uses. It's a quarter of
+// the table size, so the tables never get too full. The tables can't grow, and
+// a completely full table never finds an empty slot to end a probe sequence.
+const fuzzKeySpace = 8192
+
+// FuzzOps decodes the input into a sequence of operations and runs them against
+// each table and a Go map, failing if the table ever disagrees with the map.
+//
+// The hash seeds are chosen at random each time the program starts, so the
+// corpus can't hold keys that are certain to collide, and with a good hash
+// keys sharing h1 or a starting group are rare. opFill and opDeleteEvery let
+// short inputs build crowded tables full of long probe sequences and deleted
+// slots, but to force collisions run FuzzOpsWeakHash with -tags weakhash.
+func FuzzOps(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(runFuzzTables)
+}
+
+// addFuzzSeeds adds the seed corpus for FuzzOps.
+func addFuzzSeeds(f *testing.F) {
+	for _, ops := range [][]byte{
+		fuzzOps(opSet, 1, opGet, 1, opGet, 2),
+		fuzzOps(opSet, 1, opDelete, 1, opGet, 1, opSet, 1, opGet, 1),
+		fuzzOps(opFill, 100, opClear, 0, opGet, 50, opSet, 50, opGet, 50),
+		fuzzOps(opFill, 8000, opDeleteEvery, 2, opGet, 7999, opSet, 8100, opFill, 8191),
+		fuzzOps(opFill, 8000, opDeleteEvery, 1, opFill, 4000, opDeleteEvery, 3, opGet, 3),
+	} {
+		f.Add(ops)
+	}
+}
+
+// fuzzTable is a table for FuzzOps to run against.
+type fuzzTable struct {
+	name string
+	m    mapper
+}
+
+// runFuzzTables runs the operations in data against a new instance of each
+// table.
+//
+// SwissUint64, SwissUint32 and SwissOffHeap have no Delete or Clear. They're
+// about how fast Set and Get can go with integer keys. So for them runFuzzOps
+// skips opDelete, opClear and opDeleteEvery, and only checks Set and Get.
+func runFuzzTables(t *testing.T, data []byte) {
+	tables := []fuzzTable{
+		{"SimpleTable", hashblog.NewSimpleTable[string, int]()},
+		{"SimpleTableProbe", hashblog.NewSimpleTableProbe[string, int]()},
+		{"GroupTable", hashblog.NewGroupTable[string, int]()},
+		{"GroupTableCtrl", hashblog.NewGroupTableCtrl[string, int]()},
+		{"SwissTable", hashblog.NewSwissTable[string, int]()},
+		{"SwissConcrete", hashblog.NewSwissConcrete()},
+		{"DoubleSwiss", hashblog.NewDoubleSwiss()},
+		{"SwissArena", hashblog.NewSwissArena()},
+		{"SwissTableBloom", newSwissTableBloom()},
+		{"SwissConcreteBloom", newSwissConcreteBloom()},
+		{"OrderedSwiss", hashblog.NewOrderedSwiss[string, int]()},
+		{"SwissUint64", uintKeys[uint64, int]{hashblog.NewSwissUint64()}},
+		{"SwissUint32", uintKeys[uint32, int]{hashblog.NewSwissUint32()}},
+	}
+	tables = append(tables, platformFuzzTables(t)...)
+	for _, test := range tables {
+		runFuzzOps(t, test.name, test.m, data)
+	}
+}
+
+// uintKeys lets FuzzOps use a table with integer keys and values. The keys
+// FuzzOps uses are all decimal numbers below fuzzKeySpace.
+type uintKeys[K uint32 | uint64, V int | uint64] struct {
+	m interface {
+		Set(key K, value V)
+		Get(key K) (V, bool)
+	}
+}
+
+func (u uintKeys[K, V]) Set(key string, value int) {
+	u.m.Set(u.key(key), V(value))
+}
+
+func (u uintKeys[K, V]) Get(key string) (int, bool) {
+	v, ok := u.m.Get(u.key(key))
+	return int(v), ok
+}
+
+func (u uintKeys[K, V]) key(key string) K {
+	k, err := strconv.ParseUint(key, 10, 64)
+	if err != nil {
+		panic(err)
+	}
+	return K(k)
+}
+
+// fuzzOps encodes pairs of operation and argument.
+func fuzzOps(ops ...int) []byte {
+	var data []byte
+	for i := 0; i < len(ops); i += 2 {
+		data = append(data, byte(ops[i]))
+		data = binary.LittleEndian.AppendUint16(data, uint16(ops[i+1]))
+	}
+	return data
+}
+
+// runFuzzOps runs the operations in data against m and a Go map. If m doesn't
+// support an operation we skip it for both.
+func runFuzzOps(t *testing.T, name string, m mapper, data []byte) {
+	ref := make(map[string]int)
+	check := func(key string) {
+		t.Helper()
+		val, ok := m.Get(key)
+		refVal, refOK := ref[key]
+		if ok != refOK || val != refVal {
+			t.Fatalf("%s: Get(%q) returned %d, %t. Expected %d, %t", name, key, val, ok, refVal, refOK)
+		}
+	}
+
+	for ; len(data) >= 3; data = data[3:] {
+		arg := int(binary.LittleEndian.Uint16(data[1:]))
+		key := strconv.Itoa(arg % fuzzKeySpace)
+
+		switch data[0] % numOps {
+		case opSet:
+			m.Set(key, arg)
+			ref[key] = arg
+		case opGet:
+			check(key)
+		case opDelete:
+			if d, ok := m.(deleter); ok {
+				d.Delete(key)
+				delete(ref, key)
+			}
+		case opClear:
+			if c, ok := m.(clearer); ok {
+				c.Clear()
+				clear(ref)
+			}
+		case opFill:
+			for i := range arg % fuzzKeySpace {
+				key := strconv.Itoa(i)
+				m.Set(key, i)
+				ref[key] = i
+			}
+		case opDeleteEvery:
+			if d, ok := m.(deleter); ok {
+				for i := 0; i < fuzzKeySpace; i += max(arg, 1) {
+					key := strconv.Itoa(i)
+					d.Delete(key)
+					delete(ref, key)
+				}
+			}
+		}
+	}
+
+	for key := range ref {
+		check(key)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package hashblog_test
+
+import (
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+// platformFuzzTables returns the tables FuzzOps can only run on this platform.
+func platformFuzzTables(t *testing.T) []fuzzTable {
+	m, err := hashblog.NewSwissOffHeap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return []fuzzTable{{"SwissOffHeap", uintKeys[uint64, uint64]{m}}}
+}
@@ -30,6 +30,11 @@ func (m *GroupTable[K, V]) Get(key K) (v V, ok bool) {
 	return v, false
 }
 
+// Clear removes everything from the table.
+func (m *GroupTable[K, V]) Clear() {
+	clear(m.groups[:])
+}
+
 func (m *GroupTable[K, V]) find(key K) (*entry[K, V], bool) {
 	h := hash(key)
 
@@ -11,7 +11,11 @@ package hashblog
 // code into the Set and Get methods. This is because we need to update the
 // control bytes as well as the entries.
 type GroupTableCtrl[K comparable, V any] struct {
-	groups [groupTableSize]groupWithCtrl[K, V]
+	counters opCounters
+	groups   [groupTableSize]groupWithCtrl[K, V]
+	len      int
+	// tombstones is the number of deleted markers. See tombstones.
+	tombstones tombstones
 }
 
 func NewGroupTableCtrl[K comparable, V any]() *GroupTableCtrl[K, V] {
@@ -26,6 +30,7 @@ func NewGroupTableCtrl[K comparable, V any]() *GroupTableCtrl[K, V] {
 }
 
 func (m *GroupTableCtrl[K, V]) Set(key K, value V) {
+	m.counters.set()
 	h := hash(key)
 
 	// h1 is the control byte value (bottom 7 bits of hash, top bit clear to
@@ -37,20 +42,46 @@ func (m *GroupTableCtrl[K, V]) Set(key K, value V) {
 	h1 := byte(h & 0x7F)
 	h2 := (h >> 7)
 
+	// If we pass a deleted slot we remember it, and if the key isn't in the
+	// table we put it there rather than in the empty slot at the end of the
+	// probe sequence.
+	var slotGroup *groupWithCtrl[K, V]
+	var slot int
+
 	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		m.counters.probeGroup()
 		g := &m.groups[seq.offset]
 		// Is the key in this group?
 		for i, ctrl := range g.ctrl {
 			switch ctrl {
 			case h1:
+				m.counters.compareKey()
 				if e := &g.entries[i]; e.key == key {
+					m.counters.overwrite()
 					e.value = value
 					return
 				}
+				m.counters.falsePositive()
+			case ctrlDeleted:
+				if slotGroup == nil {
+					slotGroup, slot = g, i
+				}
 			case 0x80:
 				// Empty slot - this means the key is not present in the table
-				g.ctrl[i] = h1
-				g.entries[i] = entry[K, V]{key: key, value: value}
+				if slotGroup == nil {
+					slotGroup, slot = g, i
+				}
+				m.counters.insert()
+				if m.tombstones.tooMany(m.len) {
+					// Rehashing moves everything, including the slot we found.
+					m.rehash()
+					m.insert(key, value, h)
+					return
+				}
+				m.tombstones.reused(slotGroup.ctrl[slot])
+				slotGroup.ctrl[slot] = h1
+				slotGroup.entries[slot] = entry[K, V]{key: key, value: value}
+				m.len++
 				return
 			}
 		}
@@ -63,23 +94,111 @@ func (m *GroupTableCtrl[K, V]) Get(key K) (v V, ok bool) {
 	h1 := byte(h & 0x7F)
 	h2 := (h >> 7)
 
+	m.counters.get()
 	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		m.counters.probeGroup()
 		g := &m.groups[seq.offset]
 		// Is the key in this group?
 		for i := range g.ctrl {
 			ctrl := g.ctrl[i]
 			if ctrl == 0x80 {
+				m.counters.miss()
 				return v, false
 			}
 			if ctrl == h1 {
+				m.counters.compareKey()
 				if e := &g.entries[i]; e.key == key {
+					m.counters.hit()
 					return e.value, true
 				}
+				m.counters.falsePositive()
 			}
 		}
 	}
 }
 
+// Delete removes key from the table.
+//
+// We can't just mark the slot empty, as there may be keys whose probe sequence
+// continued past this slot. If we marked the slot empty lookups for those keys
+// would stop here. So instead we mark it deleted. Get carries on past deleted
+// slots, and Set reuses them or rehashes when there are too many. See
+// tombstones.
+//
+// Unlike SwissTable we always need the marker, even if the group has an empty
+// slot: Get stops at the first empty slot, which may come before this one.
+func (m *GroupTableCtrl[K, V]) Delete(key K) {
+	h := hash(key)
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		for i, ctrl := range g.ctrl {
+			switch ctrl {
+			case 0x80:
+				return
+			case h1:
+				if e := &g.entries[i]; e.key == key {
+					// Clear the entry so we don't hold on to anything the key or
+					// value points to.
+					*e = entry[K, V]{}
+					g.ctrl[i] = ctrlDeleted
+					m.tombstones.deleted(true)
+					m.len--
+					return
+				}
+			}
+		}
+	}
+}
+
+// Clear removes everything from the table.
+func (m *GroupTableCtrl[K, V]) Clear() {
+	for i := range m.groups {
+		m.groups[i] = groupWithCtrl[K, V]{ctrl: groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}}
+	}
+	m.len, m.tombstones = 0, 0
+}
+
+// rehash removes the deleted markers by taking every entry out and inserting
+// it again. See tombstones.
+func (m *GroupTableCtrl[K, V]) rehash() {
+	all := make([]entry[K, V], 0, m.len)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl < 0x80 {
+				all = append(all, g.entries[i])
+			}
+		}
+	}
+	m.Clear()
+	for _, e := range all {
+		m.insert(e.key, e.value, hash(e.key))
+	}
+}
+
+// insert adds key, which has hash h and isn't already in the table, to the
+// first empty slot on its probe sequence.
+func (m *GroupTableCtrl[K, V]) insert(key K, value V, h hashValue) {
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		for i, ctrl := range g.ctrl {
+			if ctrl == 0x80 {
+				g.ctrl[i] = byte(h & 0x7F)
+				g.entries[i] = entry[K, V]{key: key, value: value}
+				m.len++
+				return
+			}
+		}
+	}
+}
+
+// ctrlDeleted is the control byte for a slot whose entry has been deleted. Like
+// an empty slot it has the top bit set, so it never matches h1.
+const ctrlDeleted = 0xFE
+
 type groupWithCtrl[K comparable, V any] struct {
 	ctrl    groupCtrl
 	entries [groupSize]entry[K, V]
@@ -0,0 +1,280 @@
+// Package hashblogtest checks that hash tables behave like Go maps.
+//
+// It's intended for the tables in hashblog, and for tables built on them.
+// RunConformance runs a table through a series of operations, doing the same
+// things to a Go map as it goes, and fails if the table and the map ever
+// disagree.
+package hashblogtest
+
+import (
+	"io"
+	"iter"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// Map is the interface every table in hashblog implements.
+type Map[K comparable, V any] interface {
+	Set(key K, value V)
+	Get(key K) (V, bool)
+}
+
+// Deleter is implemented by tables that support removing keys. RunConformance
+// only tests deletion for tables that implement it.
+type Deleter[K comparable] interface {
+	Delete(key K)
+}
+
+// Ranger is implemented by tables that support iteration. RunConformance only
+// tests iteration for tables that implement it.
+type Ranger[K comparable, V any] interface {
+	All() iter.Seq2[K, V]
+}
+
+// Option changes what RunConformance expects of a table.
+type Option func(*options)
+
+type options struct {
+	capacity  int
+	noZeroKey bool
+}
+
+// Capacity tells RunConformance how many entries the table can hold. The
+// default is 32768, which is the size of all the tables in hashblog.
+func Capacity(n int) Option {
+	return func(o *options) { o.capacity = n }
+}
+
+// NoZeroKey tells RunConformance not to store the zero key in the table. This
+// is for tables like hashblog.SimpleTable that use the zero key to mark empty
+// slots.
+func NoZeroKey() Option {
+	return func(o *options) { o.noZeroKey = true }
+}
+
+// RunConformance checks a table type against a Go map. newFn must return a
+// new, empty table each time it is called. If the table implements io.Closer
+// it is closed at the end of each test.
+//
+// K and V must be strings, integers, floats or bools, or types based on them,
+// so that RunConformance can make up keys and values.
+func RunConformance[K, V comparable, M Map[K, V]](t *testing.T, newFn func() M, opts ...Option) {
+	o := options{capacity: 32768}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	newTable := func(t *testing.T) *checker[K, V] {
+		m := newFn()
+		if c, ok := any(m).(io.Closer); ok {
+			t.Cleanup(func() {
+				if err := c.Close(); err != nil {
+					t.Errorf("closing table: %v", err)
+				}
+			})
+		}
+		return &checker[K, V]{t: t, m: m, ref: make(map[K]V)}
+	}
+
+	t.Run("GetMissing", func(t *testing.T) {
+		c := newTable(t)
+		c.get(key[K](t, 1))
+		c.set(key[K](t, 2), value[V](t, 2))
+		c.get(key[K](t, 1))
+	})
+
+	t.Run("GetPresent", func(t *testing.T) {
+		c := newTable(t)
+		c.set(key[K](t, 1), value[V](t, 42))
+		c.get(key[K](t, 1))
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		c := newTable(t)
+		c.set(key[K](t, 1), value[V](t, 1))
+		c.set(key[K](t, 1), value[V](t, 2))
+		c.get(key[K](t, 1))
+	})
+
+	t.Run("ZeroKey", func(t *testing.T) {
+		if o.noZeroKey {
+			t.Skip("table can't store the zero key")
+		}
+		c := newTable(t)
+		var zero K
+		c.get(zero)
+		c.set(zero, value[V](t, 1))
+		c.fill(1, 1000)
+		c.get(zero)
+		c.set(zero, value[V](t, 2))
+		c.checkAll()
+	})
+
+	t.Run("FillGradually", func(t *testing.T) {
+		// None of our tables grow, so this only checks that every key is still
+		// there after each step as the table fills to half capacity.
+		c := newTable(t)
+		n := o.capacity / 2
+		for start := 1; start <= n; start += n / 8 {
+			c.fill(start, start+n/8)
+			c.checkAll()
+		}
+		c.checkMisses(n+1, n+1000)
+	})
+
+	t.Run("NearlyFull", func(t *testing.T) {
+		// At 7/8 full, ordinary keys share groups and probe sequences get
+		// long. Forcing keys to share h1 or a group needs a weak hash; the
+		// weakhash-tagged tests in hashblog run this suite with one.
+		c := newTable(t)
+		n := o.capacity * 7 / 8
+		c.fill(1, n+1)
+		c.checkAll()
+		c.checkMisses(n+1, n+o.capacity)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c := newTable(t)
+		if _, ok := c.m.(Deleter[K]); !ok {
+			t.Skip("table doesn't support Delete")
+		}
+		c.fill(1, 2001)
+		for i := 1; i <= 2000; i += 2 {
+			c.delete(key[K](t, i))
+		}
+		c.checkAll()
+		c.checkMisses(2001, 3001)
+
+		// Deleting a missing key does nothing.
+		c.delete(key[K](t, 1))
+		c.delete(key[K](t, 5000))
+
+		// Put back some of the deleted keys.
+		for i := 1; i <= 1000; i += 2 {
+			c.set(key[K](t, i), value[V](t, i+1))
+		}
+		c.checkAll()
+	})
+
+	t.Run("Iteration", func(t *testing.T) {
+		c := newTable(t)
+		r, ok := c.m.(Ranger[K, V])
+		if !ok {
+			t.Skip("table doesn't support All")
+		}
+		c.fill(1, 1001)
+		if _, ok := c.m.(Deleter[K]); ok {
+			for i := 1; i <= 1000; i += 3 {
+				c.delete(key[K](t, i))
+			}
+		}
+
+		seen := make(map[K]V, len(c.ref))
+		for k, v := range r.All() {
+			if _, ok := seen[k]; ok {
+				t.Fatalf("key %v seen twice", k)
+			}
+			seen[k] = v
+		}
+		if !reflect.DeepEqual(seen, c.ref) {
+			t.Fatalf("iteration returned %d entries, expected %d, or values differ", len(seen), len(c.ref))
+		}
+
+		// Stopping early must work.
+		for range r.All() {
+			break
+		}
+	})
+}
+
+// checker applies operations to a table and a reference map, and checks they
+// agree.
+type checker[K, V comparable] struct {
+	t   *testing.T
+	m   Map[K, V]
+	ref map[K]V
+}
+
+func (c *checker[K, V]) set(k K, v V) {
+	c.m.Set(k, v)
+	c.ref[k] = v
+}
+
+func (c *checker[K, V]) delete(k K) {
+	c.m.(Deleter[K]).Delete(k)
+	delete(c.ref, k)
+}
+
+func (c *checker[K, V]) get(k K) {
+	c.t.Helper()
+	v, ok := c.m.Get(k)
+	refV, refOK := c.ref[k]
+	if ok != refOK {
+		c.t.Fatalf("Get(%v): expected ok == %t, got %t", k, refOK, ok)
+	}
+	if ok && v != refV {
+		c.t.Fatalf("Get(%v): expected value %v, got %v", k, refV, v)
+	}
+}
+
+// fill sets keys from start up to but not including end.
+func (c *checker[K, V]) fill(start, end int) {
+	for i := start; i < end; i++ {
+		c.set(key[K](c.t, i), value[V](c.t, i))
+	}
+}
+
+// checkAll checks every key in the reference map is in the table.
+func (c *checker[K, V]) checkAll() {
+	c.t.Helper()
+	for k := range c.ref {
+		c.get(k)
+	}
+}
+
+// checkMisses checks keys from start up to but not including end.
+func (c *checker[K, V]) checkMisses(start, end int) {
+	c.t.Helper()
+	for i := start; i < end; i++ {
+		c.get(key[K](c.t, i))
+	}
+}
+
+// key returns the i'th key. i must be greater than zero, and no two i give the
+// same key. That means no key is the zero key.
+func key[K comparable](t *testing.T, i int) (k K) {
+	fromInt(t, reflect.ValueOf(&k).Elem(), i, true)
+	return k
+}
+
+// value returns a value for i. Unlike keys, values may repeat.
+func value[V comparable](t *testing.T, i int) (v V) {
+	fromInt(t, reflect.ValueOf(&v).Elem(), i, false)
+	return v
+}
+
+// fromInt sets v to a value derived from i. If distinct is true it fails the
+// test rather than give the same value for two different i.
+func fromInt(t *testing.T, v reflect.Value, i int, distinct bool) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(strconv.Itoa(i))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(i))
+		if distinct && v.Int() != int64(i) {
+			t.Fatalf("%s is too small for %d keys", v.Type(), i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(uint64(i))
+		if distinct && v.Uint() != uint64(i) {
+			t.Fatalf("%s is too small for %d keys", v.Type(), i)
+		}
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(i))
+	case reflect.Bool:
+		v.SetBool(i%2 == 0)
+	default:
+		t.Fatalf("can't make keys or values of type %s", v.Type())
+	}
+}
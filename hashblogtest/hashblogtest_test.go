@@ -0,0 +1,36 @@
+package hashblogtest_test
+
+import (
+	"iter"
+	"maps"
+	"testing"
+
+	"github.com/philpearl/hashblog/hashblogtest"
+)
+
+// goMap wraps a Go map, so we can check the conformance tests pass for a table
+// we know is right, including the optional Delete and All.
+type goMap[K comparable, V any] map[K]V
+
+func (m goMap[K, V]) Set(key K, value V) { m[key] = value }
+
+func (m goMap[K, V]) Get(key K) (V, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+func (m goMap[K, V]) Delete(key K) { delete(m, key) }
+
+func (m goMap[K, V]) All() iter.Seq2[K, V] { return maps.All(m) }
+
+func TestGoMap(t *testing.T) {
+	t.Run("string", func(t *testing.T) {
+		hashblogtest.RunConformance(t, func() goMap[string, int] { return make(goMap[string, int]) })
+	})
+	t.Run("uint32", func(t *testing.T) {
+		hashblogtest.RunConformance(t, func() goMap[uint32, float64] { return make(goMap[uint32, float64]) })
+	})
+	t.Run("int16", func(t *testing.T) {
+		hashblogtest.RunConformance(t, func() goMap[int16, int8] { return make(goMap[int16, int8]) }, hashblogtest.Capacity(1000))
+	})
+}
@@ -0,0 +1,193 @@
+package hashblog_test
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/philpearl/hashblog"
+)
+
+// latencyBatch is the number of lookups we time together. Reading the clock
+// takes about as long as a lookup, so timing lookups one at a time would mostly
+// measure the clock. Timing a few together and dividing keeps the clock's share
+// small while still catching the slow lookups. It does blur the tail a little:
+// one slow lookup in a batch is averaged with the others.
+const latencyBatch = 8
+
+// latencyResolution is the width of each bucket in the latency histogram.
+const latencyResolution = 0.25
+
+// latencies is a histogram of lookup latencies. We use a histogram rather than
+// keeping every sample so the benchmark's memory use doesn't depend on b.N.
+//
+// We can only time a batch of lookups, so each lookup is counted at the mean
+// latency of its batch. count is the number of lookups.
+type latencies struct {
+	buckets [16384]uint64
+	count   uint64
+	max     float64
+}
+
+// add records a batch of n lookups that took d.
+func (l *latencies) add(d time.Duration, n int) {
+	ns := float64(d.Nanoseconds()) / float64(n)
+	l.buckets[min(int(ns/latencyResolution), len(l.buckets)-1)] += uint64(n)
+	l.count += uint64(n)
+	l.max = max(l.max, ns)
+}
+
+// percentile returns the latency in ns that p percent of lookups beat. A slow
+// lookup is averaged with the rest of its batch, so this understates the tail a
+// little. See latencyBatch.
+func (l *latencies) percentile(p float64) float64 {
+	want := uint64(math.Ceil(float64(l.count) * p / 100))
+	var seen uint64
+	for i, n := range l.buckets[:len(l.buckets)-1] {
+		if seen += n; seen >= want {
+			return float64(i) * latencyResolution
+		}
+	}
+	// The last bucket holds everything too slow for the others.
+	return l.max
+}
+
+func (l *latencies) report(b *testing.B) {
+	for _, p := range []struct {
+		name string
+		p    float64
+	}{
+		{"p50", 50},
+		{"p90", 90},
+		{"p99", 99},
+		{"p99.9", 99.9},
+	} {
+		b.ReportMetric(l.percentile(p.p), p.name+"-ns")
+	}
+}
+
+// latencyTables are the tables the latency benchmarks run against.
+func latencyTables() []struct {
+	name string
+	m    mapper
+} {
+	return []struct {
+		name string
+		m    mapper
+	}{
+		{"SimpleTable", hashblog.NewSimpleTable[string, int]()},
+		{"SimpleTableProbe", hashblog.NewSimpleTableProbe[string, int]()},
+		{"GroupTable", hashblog.NewGroupTable[string, int]()},
+		{"GroupTableCtrl", hashblog.NewGroupTableCtrl[string, int]()},
+		{"Swiss", hashblog.NewSwissTable[string, int]()},
+		{"SwissConcrete", hashblog.NewSwissConcrete()},
+		{"DoubleSwiss", hashblog.NewDoubleSwiss()},
+		{"SwissArena", hashblog.NewSwissArena()},
+		{"map", stdMap{}},
+	}
+}
+
+type stdMap map[string]int
+
+func (m stdMap) Set(key string, value int) { m[key] = value }
+func (m stdMap) Get(key string) (int, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+// BenchmarkGetLatency reports the distribution of lookup latencies for keys
+// that are in the table, as well as the mean.
+func BenchmarkGetLatency(b *testing.B) {
+	benchmarkLatency(b, false)
+}
+
+// BenchmarkMissLatency reports the distribution of lookup latencies for keys
+// that aren't in the table. This is where SimpleTable's long runs of full
+// slots hurt.
+func BenchmarkMissLatency(b *testing.B) {
+	benchmarkLatency(b, true)
+}
+
+// benchmarkLatency times lookups in batches of latencyBatch. The ns/op it
+// reports includes the time spent reading the clock, so it's higher than
+// BenchmarkGet and BenchmarkMiss report.
+func benchmarkLatency(b *testing.B, miss bool) {
+	for _, size := range []int{1000, 8000, 16000, 24000} {
+		keys := make([]string, size*2)
+		for i := range keys {
+			keys[i] = strconv.Itoa(i)
+		}
+		lookups := keys[:size]
+		if miss {
+			lookups = keys[size:]
+		}
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			for _, test := range latencyTables() {
+				m := test.m
+				b.Run("i="+test.name, func(b *testing.B) {
+					for i, key := range keys[:size] {
+						m.Set(key, i)
+					}
+					var l latencies
+					b.ReportAllocs()
+					b.ResetTimer()
+					for b.Loop() {
+						for i := 0; i < len(lookups); i += latencyBatch {
+							batch := lookups[i:min(i+latencyBatch, len(lookups))]
+							start := time.Now()
+							for _, key := range batch {
+								m.Get(key)
+							}
+							l.add(time.Since(start), len(batch))
+						}
+					}
+					b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+					l.report(b)
+				})
+			}
+		})
+	}
+}
+
+func TestLatencyPercentile(t *testing.T) {
+	var l latencies
+	for i := range 1000 {
+		l.add(time.Duration(i+1), 1)
+	}
+	l.add(time.Hour, 1)
+
+	for _, test := range []struct {
+		p    float64
+		want float64
+	}{
+		{50, 501},
+		{90, 901},
+		{99, 991},
+		{99.9, 1000},
+		{100, float64(time.Hour)},
+	} {
+		if got := l.percentile(test.p); got != test.want {
+			t.Errorf("p%g: expected %g, got %g", test.p, test.want, got)
+		}
+	}
+}
+
+// TestLatencyPercentileBatches checks that a batch counts once for each lookup
+// in it, so a short batch doesn't count as much as a full one.
+func TestLatencyPercentileBatches(t *testing.T) {
+	var l latencies
+	// 9 full batches at 10ns a lookup, then 1 lookup on its own at 100ns.
+	for range 9 {
+		l.add(10*latencyBatch, latencyBatch)
+	}
+	l.add(100, 1)
+
+	if got := l.percentile(98); got != 10 {
+		t.Errorf("p98: expected 10, got %g", got)
+	}
+	if got := l.percentile(99); got != 100 {
+		t.Errorf("p99: expected 100, got %g", got)
+	}
+}
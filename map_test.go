@@ -2,10 +2,13 @@ package hashblog_test
 
 import (
 	"fmt"
+	"runtime"
 	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/philpearl/hashblog"
+	"github.com/philpearl/hashblog/hashblogtest"
 )
 
 type mapper interface {
@@ -13,75 +16,160 @@ type mapper interface {
 	Get(key string) (int, bool)
 }
 
-func TestGetMissing(t *testing.T) {
-	for _, m := range []mapper{
-		hashblog.NewSimpleTable[string, int](),
-		hashblog.NewSimpleTableProbe[string, int](),
-		hashblog.NewGroupTable[string, int](),
-		hashblog.NewGroupTableCtrl[string, int](),
-		hashblog.NewSwissTable[string, int](),
-		hashblog.NewSwissConcrete(),
-		hashblog.NewDoubleSwiss(),
+func TestConformance(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		new  func() mapper
+		opts []hashblogtest.Option
+	}{
+		// The first three tables use the zero key to mark empty slots.
+		{"SimpleTable", func() mapper { return hashblog.NewSimpleTable[string, int]() }, []hashblogtest.Option{hashblogtest.NoZeroKey()}},
+		{"SimpleTableProbe", func() mapper { return hashblog.NewSimpleTableProbe[string, int]() }, []hashblogtest.Option{hashblogtest.NoZeroKey()}},
+		{"GroupTable", func() mapper { return hashblog.NewGroupTable[string, int]() }, []hashblogtest.Option{hashblogtest.NoZeroKey()}},
+		{"GroupTableCtrl", func() mapper { return hashblog.NewGroupTableCtrl[string, int]() }, nil},
+		{"SwissTable", func() mapper { return hashblog.NewSwissTable[string, int]() }, nil},
+		{"SwissConcrete", func() mapper { return hashblog.NewSwissConcrete() }, nil},
+		{"DoubleSwiss", func() mapper { return hashblog.NewDoubleSwiss() }, nil},
+		{"SwissArena", func() mapper { return hashblog.NewSwissArena() }, nil},
+		{"SwissTableBloom", func() mapper { return newSwissTableBloom() }, nil},
+		{"SwissConcreteBloom", func() mapper { return newSwissConcreteBloom() }, nil},
+		{"OrderedSwiss", func() mapper { return hashblog.NewOrderedSwiss[string, int]() }, nil},
 	} {
-		t.Run(fmt.Sprintf("%T", m), func(t *testing.T) {
-			if _, ok := m.Get("missing"); ok {
-				t.Fatalf("expected missing key to return ok == false")
-			}
-			m.Set("present", 42)
-
-			if _, ok := m.Get("missing"); ok {
-				t.Fatalf("expected missing key to return ok == false")
-			}
+		t.Run(test.name, func(t *testing.T) {
+			hashblogtest.RunConformance(t, test.new, test.opts...)
 		})
 	}
 }
 
-func TestGetPresent(t *testing.T) {
-	for _, m := range []mapper{
-		hashblog.NewSimpleTable[string, int](),
-		hashblog.NewSimpleTableProbe[string, int](),
-		hashblog.NewGroupTable[string, int](),
-		hashblog.NewGroupTableCtrl[string, int](),
+type counted interface {
+	Counters() hashblog.Counters
+}
+
+// counterReport reports how many groups and key comparisons each operation in
+// a benchmark takes. It only reports anything when the tables are built with the
+// counters build tag.
+type counterReport struct {
+	m      counted
+	before hashblog.Counters
+}
+
+func startCounters(m counted) counterReport {
+	return counterReport{m: m, before: m.Counters()}
+}
+
+func (c counterReport) report(b *testing.B, opsPerLoop int) {
+	if !hashblog.CountersEnabled {
+		return
+	}
+	after := c.m.Counters()
+	ops := float64(b.N) * float64(opsPerLoop)
+	b.ReportMetric(float64(after.GroupsProbed-c.before.GroupsProbed)/ops, "groups/op")
+	b.ReportMetric(float64(after.KeyComparisons-c.before.KeyComparisons)/ops, "keycmp/op")
+}
+
+type batchMapper interface {
+	mapper
+	GetBatch(keys []string, out []int, found []bool)
+}
+
+func TestGetBatch(t *testing.T) {
+	for _, m := range []batchMapper{
 		hashblog.NewSwissTable[string, int](),
 		hashblog.NewSwissConcrete(),
 		hashblog.NewDoubleSwiss(),
 	} {
 		t.Run(fmt.Sprintf("%T", m), func(t *testing.T) {
-			m.Set("present", 42)
+			// Use enough keys to need several batches, and make every other
+			// key a miss.
+			keys := make([]string, 1000)
+			for i := range keys {
+				keys[i] = strconv.Itoa(i)
+				if i%2 == 0 {
+					m.Set(keys[i], i)
+				}
+			}
+
+			out := make([]int, len(keys))
+			found := make([]bool, len(keys))
+			m.GetBatch(keys, out, found)
 
-			val, ok := m.Get("present")
-			if !ok {
-				t.Fatalf("expected present key to return ok == true")
+			for i, key := range keys {
+				if want := i%2 == 0; found[i] != want {
+					t.Fatalf("key %s: expected found == %t", key, want)
+				}
+				if found[i] && out[i] != i {
+					t.Fatalf("key %s: expected value %d, got %d", key, i, out[i])
+				}
 			}
 
-			if val != 42 {
-				t.Fatalf("expected value 42, got %d", val)
+			// GetBatch only reads the table, so it's safe to call from many
+			// goroutines at once. Run with -race to check.
+			var wg sync.WaitGroup
+			for range 4 {
+				wg.Go(func() {
+					out := make([]int, len(keys))
+					found := make([]bool, len(keys))
+					m.GetBatch(keys, out, found)
+				})
 			}
+			wg.Wait()
 		})
 	}
 }
 
-func TestOverwrite(t *testing.T) {
-	for _, m := range []mapper{
-		hashblog.NewSimpleTable[string, int](),
-		hashblog.NewSimpleTableProbe[string, int](),
-		hashblog.NewGroupTable[string, int](),
-		hashblog.NewGroupTableCtrl[string, int](),
-		hashblog.NewSwissTable[string, int](),
-		hashblog.NewSwissConcrete(),
-		hashblog.NewDoubleSwiss(),
+// TestChurn keeps a crowded table at the same size while deleting old keys and
+// adding new ones. Without rehashing, deleted markers build up until there are
+// no empty slots left and lookups for missing keys never finish.
+func TestChurn(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		new  func() mapper
+	}{
+		{"GroupTableCtrl", func() mapper { return hashblog.NewGroupTableCtrl[string, int]() }},
+		{"SwissTable", func() mapper { return hashblog.NewSwissTable[string, int]() }},
+		{"SwissConcrete", func() mapper { return hashblog.NewSwissConcrete() }},
+		{"DoubleSwiss", func() mapper { return hashblog.NewDoubleSwiss() }},
+		{"SwissArena", func() mapper { return hashblog.NewSwissArena() }},
+		{"OrderedSwiss", func() mapper { return hashblog.NewOrderedSwiss[string, int]() }},
 	} {
-		t.Run(fmt.Sprintf("%T", m), func(t *testing.T) {
-			m.Set("key", 1)
-			m.Set("key", 2)
-
-			val, ok := m.Get("key")
-			if !ok {
-				t.Fatalf("expected key to be present")
+		t.Run(test.name, func(t *testing.T) {
+			const (
+				live   = 28000
+				batch  = 2000
+				rounds = 100
+			)
+			m := test.new()
+			d := m.(deleter)
+			for i := range live {
+				m.Set(strconv.Itoa(i), i)
 			}
+			for round := range rounds {
+				// Delete the oldest keys and add as many new ones.
+				first := round * batch
+				for i := first; i < first+batch; i++ {
+					d.Delete(strconv.Itoa(i))
+				}
+				for i := first + live; i < first+live+batch; i++ {
+					m.Set(strconv.Itoa(i), i)
+				}
 
-			if val != 2 {
-				t.Fatalf("expected value 2, got %d", val)
+				if _, ok := m.Get("missing"); ok {
+					t.Fatal("found a key we never set")
+				}
+				if s, ok := m.(statser); ok {
+					st := s.Stats()
+					if st.Entries != live {
+						t.Fatalf("round %d: expected %d entries, got %d", round, live, st.Entries)
+					}
+					if free, empty := st.Capacity-st.Entries, st.Capacity-st.Entries-st.Tombstones; empty < free/2 {
+						t.Fatalf("round %d: only %d of %d free slots are empty", round, empty, free)
+					}
+				}
+			}
+			for i := rounds * batch; i < rounds*batch+live; i++ {
+				if v, ok := m.Get(strconv.Itoa(i)); !ok || v != i {
+					t.Fatalf("key %d: got %d, %t", i, v, ok)
+				}
 			}
 		})
 	}
@@ -249,6 +337,7 @@ func BenchmarkGet(b *testing.B) {
 				for i, key := range keys {
 					m.Set(key, i)
 				}
+				c := startCounters(m)
 				b.ReportAllocs()
 				b.ResetTimer()
 				for b.Loop() {
@@ -259,12 +348,14 @@ func BenchmarkGet(b *testing.B) {
 					}
 				}
 				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+				c.report(b, size)
 			})
 			b.Run("i=Swiss", func(b *testing.B) {
 				m := hashblog.NewSwissTable[string, int]()
 				for i, key := range keys {
 					m.Set(key, i)
 				}
+				c := startCounters(m)
 				b.ReportAllocs()
 				b.ResetTimer()
 				for b.Loop() {
@@ -275,12 +366,14 @@ func BenchmarkGet(b *testing.B) {
 					}
 				}
 				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+				c.report(b, size)
 			})
 			b.Run("i=SwissConcrete", func(b *testing.B) {
 				m := hashblog.NewSwissConcrete()
 				for i, key := range keys {
 					m.Set(key, i)
 				}
+				c := startCounters(m)
 				b.ReportAllocs()
 				b.ResetTimer()
 				for b.Loop() {
@@ -291,12 +384,14 @@ func BenchmarkGet(b *testing.B) {
 					}
 				}
 				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+				c.report(b, size)
 			})
 			b.Run("i=DoubleSwiss", func(b *testing.B) {
 				m := hashblog.NewDoubleSwiss()
 				for i, key := range keys {
 					m.Set(key, i)
 				}
+				c := startCounters(m)
 				b.ReportAllocs()
 				b.ResetTimer()
 				for b.Loop() {
@@ -307,6 +402,7 @@ func BenchmarkGet(b *testing.B) {
 					}
 				}
 				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+				c.report(b, size)
 			})
 
 			b.Run("i=map", func(b *testing.B) {
@@ -390,6 +486,7 @@ func BenchmarkMiss(b *testing.B) {
 				for i, key := range keys[:size] {
 					m.Set(key, i)
 				}
+				c := startCounters(m)
 				b.ReportAllocs()
 				b.ResetTimer()
 				for b.Loop() {
@@ -400,12 +497,14 @@ func BenchmarkMiss(b *testing.B) {
 					}
 				}
 				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+				c.report(b, size)
 			})
 			b.Run("i=Swiss", func(b *testing.B) {
 				m := hashblog.NewSwissTable[string, int]()
 				for i, key := range keys[:size] {
 					m.Set(key, i)
 				}
+				c := startCounters(m)
 				b.ReportAllocs()
 				b.ResetTimer()
 				for b.Loop() {
@@ -416,12 +515,14 @@ func BenchmarkMiss(b *testing.B) {
 					}
 				}
 				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+				c.report(b, size)
 			})
 			b.Run("i=SwissConcrete", func(b *testing.B) {
 				m := hashblog.NewSwissConcrete()
 				for i, key := range keys[:size] {
 					m.Set(key, i)
 				}
+				c := startCounters(m)
 				b.ReportAllocs()
 				b.ResetTimer()
 				for b.Loop() {
@@ -432,12 +533,14 @@ func BenchmarkMiss(b *testing.B) {
 					}
 				}
 				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+				c.report(b, size)
 			})
 			b.Run("i=DoubleSwiss", func(b *testing.B) {
 				m := hashblog.NewDoubleSwiss()
 				for i, key := range keys[:size] {
 					m.Set(key, i)
 				}
+				c := startCounters(m)
 				b.ReportAllocs()
 				b.ResetTimer()
 				for b.Loop() {
@@ -448,6 +551,7 @@ func BenchmarkMiss(b *testing.B) {
 					}
 				}
 				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+				c.report(b, size)
 			})
 
 			b.Run("i=map", func(b *testing.B) {
@@ -469,3 +573,127 @@ func BenchmarkMiss(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkGetBatch(b *testing.B) {
+	for _, size := range []int{10, 100, 1000, 2000, 4000, 8000, 16000, 24000, 32768} {
+		keys := make([]string, size)
+		for i := range keys {
+			keys[i] = strconv.Itoa(i)
+		}
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			for _, test := range []struct {
+				name string
+				m    batchMapper
+			}{
+				{"Swiss", hashblog.NewSwissTable[string, int]()},
+				{"SwissConcrete", hashblog.NewSwissConcrete()},
+				{"DoubleSwiss", hashblog.NewDoubleSwiss()},
+			} {
+				m := test.m
+				b.Run("i="+test.name, func(b *testing.B) {
+					for i, key := range keys {
+						m.Set(key, i)
+					}
+					out := make([]int, size)
+					found := make([]bool, size)
+					b.ReportAllocs()
+					b.ResetTimer()
+					for b.Loop() {
+						m.GetBatch(keys, out, found)
+					}
+					b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+				})
+			}
+		})
+	}
+}
+
+// BenchmarkGetBatchCold compares Get with GetBatch when the groups aren't in
+// cache. A single full table is well under 1MB, so on most machines it fits in
+// L2 and the size benchmarks never leave it. Here we spread the lookups across
+// more and more tables, moving on to the next table after each batch of
+// lookups, so by the time we come back to a table its groups have been
+// evicted.
+func BenchmarkGetBatchCold(b *testing.B) {
+	const (
+		keysPerTable = 24000
+		lookups      = 1024
+	)
+	keys := make([]string, keysPerTable)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	out := make([]int, lookups)
+	found := make([]bool, lookups)
+
+	for _, numTables := range []int{1, 4, 16, 64, 256} {
+		tables := make([]*hashblog.SwissConcrete, numTables)
+		for i := range tables {
+			tables[i] = hashblog.NewSwissConcrete()
+			for j, key := range keys {
+				tables[i].Set(key, j)
+			}
+		}
+
+		b.Run(fmt.Sprintf("tables=%d", numTables), func(b *testing.B) {
+			for _, test := range []struct {
+				name   string
+				lookup func(m *hashblog.SwissConcrete, keys []string)
+			}{
+				{"Get", func(m *hashblog.SwissConcrete, keys []string) {
+					for i, key := range keys {
+						out[i], found[i] = m.Get(key)
+					}
+				}},
+				{"GetBatch", func(m *hashblog.SwissConcrete, keys []string) {
+					m.GetBatch(keys, out, found)
+				}},
+			} {
+				b.Run("i="+test.name, func(b *testing.B) {
+					var start int
+					b.ReportAllocs()
+					for b.Loop() {
+						for _, m := range tables {
+							test.lookup(m, keys[start:start+lookups])
+							start = (start + lookups) % (keysPerTable - lookups)
+						}
+					}
+					b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(numTables*lookups), "ns/op")
+				})
+			}
+		})
+	}
+}
+
+// BenchmarkGCPause measures how long a GC takes with a million string keys held
+// in tables. Each table is a fixed size, so we need quite a few of them.
+func BenchmarkGCPause(b *testing.B) {
+	const (
+		numKeys      = 1_000_000
+		keysPerTable = 28_000
+	)
+	for _, test := range []struct {
+		name string
+		new  func() mapper
+	}{
+		{"SwissConcrete", func() mapper { return hashblog.NewSwissConcrete() }},
+		{"SwissArena", func() mapper { return hashblog.NewSwissArena() }},
+	} {
+		b.Run("i="+test.name, func(b *testing.B) {
+			var tables []mapper
+			for i := range numKeys {
+				if i%keysPerTable == 0 {
+					tables = append(tables, test.new())
+				}
+				tables[len(tables)-1].Set(strconv.Itoa(i), i)
+			}
+			runtime.GC()
+
+			b.ResetTimer()
+			for b.Loop() {
+				runtime.GC()
+			}
+			runtime.KeepAlive(tables)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+//go:build unix
+
+package hashblog
+
+import (
+	"fmt"
+	"math/bits"
+	"syscall"
+	"unsafe"
+)
+
+// SwissOffHeap is a SwissConcrete-style table mapping uint64 keys to uint64
+// values, whose groups live in memory obtained directly from the OS with mmap
+// rather than from the Go heap.
+//
+// The GC never sees this memory, so a table costs nothing to scan or mark no
+// matter how big it is. That only works because the keys and values contain no
+// pointers: the GC wouldn't notice a pointer stored here, and would free the
+// thing it points to.
+//
+// The memory isn't freed by the GC either. You must call Close when you're
+// done with the table.
+type SwissOffHeap struct {
+	mem    []byte
+	groups *[groupTableSize]offHeapGroup
+}
+
+// NewSwissOffHeap creates a new off-heap table. Call Close to release its
+// memory.
+func NewSwissOffHeap() (*SwissOffHeap, error) {
+	mem, err := syscall.Mmap(
+		-1, 0, int(unsafe.Sizeof([groupTableSize]offHeapGroup{})),
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_ANON|syscall.MAP_PRIVATE,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("mapping memory for table: %w", err)
+	}
+
+	m := &SwissOffHeap{
+		mem:    mem,
+		groups: (*[groupTableSize]offHeapGroup)(unsafe.Pointer(unsafe.SliceData(mem))),
+	}
+	for i := range m.groups {
+		m.groups[i].ctrl = concreteCtrl(0x8080_8080_8080_8080)
+	}
+	return m, nil
+}
+
+// Close returns the table's memory to the OS. Calling Close again does nothing.
+// After Close the table reads as empty: Get finds nothing, and Stats and Trace
+// see no entries. Set panics.
+func (m *SwissOffHeap) Close() error {
+	if m.mem == nil {
+		return nil
+	}
+	mem := m.mem
+	m.mem, m.groups = nil, nil
+	if err := syscall.Munmap(mem); err != nil {
+		return fmt.Errorf("unmapping table memory: %w", err)
+	}
+	return nil
+}
+
+func (m *SwissOffHeap) Set(key uint64, value uint64) {
+	if m.groups == nil {
+		panic("hashblog: Set on closed SwissOffHeap")
+	}
+	h := intHash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101_0101_0101_0101
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; e.key == key {
+				e.value = value
+				return
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			i := bits.TrailingZeros64(empties) / 8
+			g.entries[i] = offHeapEntry{key: key, value: value}
+			g.ctrl.set(i, h1)
+			return
+		}
+	}
+}
+
+func (m *SwissOffHeap) Get(key uint64) (v uint64, ok bool) {
+	if m == nil || m.groups == nil {
+		return v, false
+	}
+	h := intHash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101_0101_0101_0101
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; e.key == key {
+				return e.value, true
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return v, false
+		}
+	}
+}
+
+// Stats walks the table and returns statistics about it.
+func (m *SwissOffHeap) Stats() Stats {
+	return concreteStats(
+		m.ctrl,
+		func(gi, i int) hashValue { return intHash(m.groups[gi].entries[i].key) },
+	)
+}
+
+// Trace looks up key, recording each group visited.
+func (m *SwissOffHeap) Trace(key uint64) LookupTrace {
+	h := intHash(key)
+	t := newTrace("SwissOffHeap", key, h, "group")
+	traceGroups(&t, h,
+		m.ctrl,
+		func(gi, i int) bool { return m.groups[gi].entries[i].key == key },
+	)
+	return t
+}
+
+// ctrl returns the control bytes of group gi. A closed table has no groups, so
+// every group reads as empty.
+func (m *SwissOffHeap) ctrl(gi int) concreteCtrl {
+	if m.groups == nil {
+		return concreteCtrl(0x8080_8080_8080_8080)
+	}
+	return m.groups[gi].ctrl
+}
+
+// offHeapGroup must not contain any pointers.
+type offHeapGroup struct {
+	ctrl    concreteCtrl
+	entries [groupSize]offHeapEntry
+}
+
+type offHeapEntry struct {
+	key   uint64
+	value uint64
+}
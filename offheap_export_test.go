@@ -0,0 +1,11 @@
+//go:build unix
+
+package hashblog
+
+import "unsafe"
+
+// Addr returns the address of the table's memory, so tests can check it has
+// been unmapped.
+func (m *SwissOffHeap) Addr() uintptr {
+	return uintptr(unsafe.Pointer(m.groups))
+}
@@ -0,0 +1,137 @@
+//go:build unix
+
+package hashblog_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+	"github.com/philpearl/hashblog/hashblogtest"
+)
+
+func TestSwissOffHeap(t *testing.T) {
+	hashblogtest.RunConformance(t, func() *hashblog.SwissOffHeap {
+		m, err := hashblog.NewSwissOffHeap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	})
+}
+
+func TestSwissOffHeapNotOnHeap(t *testing.T) {
+	const numTables = 50
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	tables := make([]*hashblog.SwissOffHeap, numTables)
+	for i := range tables {
+		m, err := hashblog.NewSwissOffHeap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables[i] = m
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	for _, m := range tables {
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each table is over 500KB, so if they were on the heap we'd see 25MB here.
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown > 1<<20 {
+		t.Fatalf("heap grew by %d bytes for %d off-heap tables", grown, numTables)
+	}
+}
+
+func TestSwissOffHeapClose(t *testing.T) {
+	if _, err := os.Stat("/proc/self/maps"); err != nil {
+		t.Skip("need /proc/self/maps to check mappings")
+	}
+
+	tables := make([]*hashblog.SwissOffHeap, 20)
+	addrs := make([]uintptr, len(tables))
+	for i := range tables {
+		m, err := hashblog.NewSwissOffHeap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Set(1, 1)
+		tables[i], addrs[i] = m, m.Addr()
+	}
+
+	for _, addr := range addrs {
+		if !isMapped(t, addr) {
+			t.Fatalf("expected table memory at %x to be mapped", addr)
+		}
+	}
+
+	for _, m := range tables {
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// Close is safe to call twice, and Get on a closed table finds
+		// nothing.
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := m.Get(1); ok {
+			t.Fatalf("expected Get on closed table to return ok == false")
+		}
+		// The other methods see an empty table too, rather than crashing.
+		if s := m.Stats(); s.Entries != 0 {
+			t.Fatalf("expected no entries in Stats of closed table, got %d", s.Entries)
+		}
+		if tr := m.Trace(1); tr.Found || len(tr.Steps) != 1 {
+			t.Fatalf("expected Trace on closed table to stop at the first group, got %+v", tr)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected Set on closed table to panic")
+				}
+			}()
+			m.Set(1, 1)
+		}()
+	}
+
+	for _, addr := range addrs {
+		if isMapped(t, addr) {
+			t.Fatalf("table memory at %x is still mapped after Close", addr)
+		}
+	}
+}
+
+// isMapped reports whether addr is within any of the process's memory mappings.
+func isMapped(t *testing.T, addr uintptr) bool {
+	t.Helper()
+	f, err := os.Open("/proc/self/maps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var start, end uintptr
+		if _, err := fmt.Sscanf(s.Text(), "%x-%x", &start, &end); err != nil {
+			t.Fatalf("parsing %q: %v", s.Text(), err)
+		}
+		if addr >= start && addr < end {
+			return true
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return false
+}
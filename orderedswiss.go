@@ -0,0 +1,232 @@
+package hashblog
+
+import (
+	"iter"
+	"math/bits"
+)
+
+// OrderedSwiss is a table that remembers the order keys were added in, like
+// the compact dict CPython has used since 3.6.
+//
+// The entries live in a slice, in the order they were added. The groups hold
+// only control bytes and the index of each entry in that slice, so they take
+// 40 bytes whatever the key and value types. Iterating walks the slice, so it
+// is fast and always in insertion order. The cost is an extra hop from the
+// groups to the slice on every lookup.
+//
+// Deleting an entry leaves a hole in the slice. When the slice runs out of
+// room we squeeze the holes out before growing it, and rebuild the groups to
+// point at the new positions. So the slice only grows if more than three
+// quarters of it is in use. Deleting may also leave a deleted marker in the
+// groups, as in SwissTable. If the slice has room the groups aren't rebuilt
+// for a while, so Set rebuilds them when the markers pile up. See tombstones.
+//
+// Like the other tables the groups can't grow, so OrderedSwiss holds at most
+// 32768 keys, and lookups never finish if it fills up completely.
+type OrderedSwiss[K comparable, V any] struct {
+	groups  [groupTableSize]orderedGroup
+	entries []orderedEntry[K, V]
+	// holes is the number of deleted entries in entries.
+	holes int
+	// tombstones is the number of deleted markers in the groups.
+	tombstones tombstones
+}
+
+type orderedGroup struct {
+	ctrl groupCtrl
+	// index holds the index in entries of the entry in each slot.
+	index [groupSize]int32
+}
+
+type orderedEntry[K comparable, V any] struct {
+	key   K
+	value V
+	// live is false if the entry has been deleted.
+	live bool
+}
+
+func NewOrderedSwiss[K comparable, V any]() *OrderedSwiss[K, V] {
+	m := &OrderedSwiss[K, V]{}
+	for i := range m.groups {
+		m.groups[i].ctrl = groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}
+	}
+	return m
+}
+
+// Set sets the value for key. If key is already present it keeps its place in
+// the order, otherwise it goes at the end.
+func (m *OrderedSwiss[K, V]) Set(key K, value V) {
+	h := hash(key)
+	if g, i := m.find(key, h); g != nil {
+		m.entries[g.index[i]].value = value
+		return
+	}
+
+	// Making room may move the entries and rebuild the groups, so we do it
+	// before choosing a slot.
+	m.makeRoom()
+	if m.tombstones.tooMany(m.Len()) {
+		m.rehash()
+	}
+	m.entries = append(m.entries, orderedEntry[K, V]{key: key, value: value, live: true})
+	m.insert(h, int32(len(m.entries)-1))
+}
+
+func (m *OrderedSwiss[K, V]) Get(key K) (v V, ok bool) {
+	if m == nil {
+		return v, false
+	}
+	if g, i := m.find(key, hash(key)); g != nil {
+		return m.entries[g.index[i]].value, true
+	}
+	return v, false
+}
+
+// Delete removes key from the table, leaving a hole in the entries.
+func (m *OrderedSwiss[K, V]) Delete(key K) {
+	g, i := m.find(key, hash(key))
+	if g == nil {
+		return
+	}
+	m.entries[g.index[i]] = orderedEntry[K, V]{}
+	m.holes++
+	m.tombstones.deleted(g.ctrl.delete(i))
+}
+
+// MoveToEnd moves key to the end of the order, as if it had been deleted and
+// added again. It reports whether key is present.
+func (m *OrderedSwiss[K, V]) MoveToEnd(key K) bool {
+	h := hash(key)
+	if g, i := m.find(key, h); g == nil {
+		return false
+	} else if int(g.index[i]) == len(m.entries)-1 {
+		return true
+	}
+
+	m.makeRoom()
+	// makeRoom may have moved key, so find it again.
+	g, i := m.find(key, h)
+	e := m.entries[g.index[i]]
+	m.entries[g.index[i]] = orderedEntry[K, V]{}
+	m.holes++
+	m.entries = append(m.entries, e)
+	g.index[i] = int32(len(m.entries) - 1)
+	return true
+}
+
+// Len returns the number of entries in the table.
+func (m *OrderedSwiss[K, V]) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.entries) - m.holes
+}
+
+// All returns an iterator over the entries in the table, in the order they
+// were added. Setting the values of keys already in the table while iterating
+// is fine, but don't add, delete or move keys.
+func (m *OrderedSwiss[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if m == nil {
+			return
+		}
+		for i := range m.entries {
+			if e := &m.entries[i]; e.live && !yield(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// Clear removes everything from the table. It keeps the entries slice to
+// reuse.
+func (m *OrderedSwiss[K, V]) Clear() {
+	for i := range m.groups {
+		m.groups[i] = orderedGroup{ctrl: groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}}
+	}
+	clear(m.entries)
+	m.entries = m.entries[:0]
+	m.holes = 0
+	m.tombstones = 0
+}
+
+// makeRoom makes sure there's room to append an entry without growing the
+// entries slice, unless there are no holes to squeeze out. If at least a
+// quarter of the entries are holes we compact the slice where it is.
+// Otherwise we compact it into a new slice twice the size.
+func (m *OrderedSwiss[K, V]) makeRoom() {
+	if len(m.entries) < cap(m.entries) || m.holes == 0 {
+		// Either there's room, or append can grow the slice without moving
+		// any entries relative to each other.
+		return
+	}
+
+	var compact []orderedEntry[K, V]
+	if m.holes*4 >= len(m.entries) {
+		compact = m.entries[:0]
+	} else {
+		compact = make([]orderedEntry[K, V], 0, 2*cap(m.entries))
+	}
+	for _, e := range m.entries {
+		if e.live {
+			compact = append(compact, e)
+		}
+	}
+	// If we compacted in place, clear the tail so we don't hold on to
+	// anything the old entries there point to.
+	clear(m.entries[len(compact):])
+	m.entries = compact
+	m.holes = 0
+	m.rehash()
+}
+
+// rehash rebuilds the groups from the live entries, which clears out any
+// deleted markers. See tombstones.
+func (m *OrderedSwiss[K, V]) rehash() {
+	for i := range m.groups {
+		m.groups[i] = orderedGroup{ctrl: groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}}
+	}
+	m.tombstones = 0
+	for i := range m.entries {
+		if e := &m.entries[i]; e.live {
+			m.insert(hash(e.key), int32(i))
+		}
+	}
+}
+
+// insert records that the key with hash h is at index in entries. It goes in
+// the first empty or deleted slot on its probe sequence. The key must not
+// already be in the groups.
+func (m *OrderedSwiss[K, V]) insert(h hashValue, index int32) {
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		if avail := g.ctrl.findEmptyOrDeleted(); avail != 0 {
+			i := bits.TrailingZeros64(avail) / 8
+			m.tombstones.reused(g.ctrl[i])
+			g.index[i] = index
+			g.ctrl[i] = byte(h & 0x7F)
+			return
+		}
+	}
+}
+
+// find returns the group and slot holding key, which has hash h, or a nil
+// group if key isn't present.
+func (m *OrderedSwiss[K, V]) find(key K, h hashValue) (*orderedGroup, int) {
+	h1Expanded := uint64(h&0x7F) * 0x0101010101010101
+
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if m.entries[g.index[i]].key == key {
+				return g, i
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return nil, 0
+		}
+	}
+}
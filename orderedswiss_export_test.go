@@ -0,0 +1,7 @@
+package hashblog
+
+// EntriesCap returns the capacity of the table's entries slice, so tests can
+// check the holes are compacted.
+func (m *OrderedSwiss[K, V]) EntriesCap() int {
+	return cap(m.entries)
+}
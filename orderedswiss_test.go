@@ -0,0 +1,195 @@
+package hashblog_test
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+// orderedKeys returns the keys of m in iteration order, checking each has the
+// value Get returns.
+func orderedKeys(t *testing.T, m *hashblog.OrderedSwiss[string, int]) []string {
+	t.Helper()
+	var keys []string
+	for k, v := range m.All() {
+		if got, ok := m.Get(k); !ok || got != v {
+			t.Fatalf("All returned %q = %d, but Get returns %d, %t", k, v, got, ok)
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) != m.Len() {
+		t.Fatalf("All returned %d keys, but Len is %d", len(keys), m.Len())
+	}
+	return keys
+}
+
+func TestOrderedSwiss(t *testing.T) {
+	m := hashblog.NewOrderedSwiss[string, int]()
+	check := func(want ...string) {
+		t.Helper()
+		if got := orderedKeys(t, m); !slices.Equal(got, want) {
+			t.Fatalf("expected keys %q, got %q", want, got)
+		}
+	}
+
+	for i, k := range []string{"c", "a", "d", "b"} {
+		m.Set(k, i)
+	}
+	check("c", "a", "d", "b")
+
+	// Overwriting keeps the key's place.
+	m.Set("a", 10)
+	check("c", "a", "d", "b")
+	if v, _ := m.Get("a"); v != 10 {
+		t.Fatalf("expected a to be 10, got %d", v)
+	}
+
+	// Deleting and adding again moves the key to the end.
+	m.Delete("c")
+	m.Delete("missing")
+	check("a", "d", "b")
+	m.Set("c", 11)
+	check("a", "d", "b", "c")
+
+	if !m.MoveToEnd("d") || !m.MoveToEnd("c") || m.MoveToEnd("missing") {
+		t.Fatalf("MoveToEnd reported the wrong keys present")
+	}
+	check("a", "b", "d", "c")
+	if v, _ := m.Get("d"); v != 2 {
+		t.Fatalf("expected d to keep its value 2, got %d", v)
+	}
+
+	// Stopping iteration early.
+	for k := range m.All() {
+		if k != "a" {
+			t.Fatalf("expected a first, got %q", k)
+		}
+		break
+	}
+
+	m.Clear()
+	check()
+	m.Set("x", 1)
+	check("x")
+
+	var nilMap *hashblog.OrderedSwiss[string, int]
+	if _, ok := nilMap.Get("x"); ok || nilMap.Len() != 0 {
+		t.Fatalf("nil map isn't empty")
+	}
+	for range nilMap.All() {
+		t.Fatalf("nil map has entries")
+	}
+}
+
+// TestOrderedSwissRandom runs random operations against the table and a slice
+// of keys in the order we expect.
+func TestOrderedSwissRandom(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	m := hashblog.NewOrderedSwiss[string, int]()
+	var order []string
+	ref := make(map[string]int)
+
+	remove := func(k string) {
+		order = slices.DeleteFunc(order, func(o string) bool { return o == k })
+	}
+
+	for i := range 200_000 {
+		k := strconv.Itoa(rng.IntN(2000))
+		switch op := rng.IntN(10); {
+		case op < 5:
+			if _, ok := ref[k]; !ok {
+				order = append(order, k)
+			}
+			m.Set(k, i)
+			ref[k] = i
+		case op < 8:
+			m.Delete(k)
+			delete(ref, k)
+			remove(k)
+		default:
+			_, ok := ref[k]
+			if got := m.MoveToEnd(k); got != ok {
+				t.Fatalf("MoveToEnd(%q) returned %t", k, got)
+			}
+			if ok {
+				remove(k)
+				order = append(order, k)
+			}
+		}
+
+		if i%10_000 == 0 {
+			if got := orderedKeys(t, m); !slices.Equal(got, order) {
+				t.Fatalf("after %d operations, keys out of order", i)
+			}
+		}
+	}
+	if got := orderedKeys(t, m); !slices.Equal(got, order) {
+		t.Fatalf("keys out of order")
+	}
+	for k, v := range ref {
+		if got, ok := m.Get(k); !ok || got != v {
+			t.Fatalf("Get(%q) = %d, %t, expected %d", k, got, ok, v)
+		}
+	}
+}
+
+// TestOrderedSwissCompact checks the holes left by deletes are squeezed out
+// rather than the entries growing forever.
+func TestOrderedSwissCompact(t *testing.T) {
+	m := hashblog.NewOrderedSwiss[int, int]()
+	for i := range 1000 {
+		m.Set(i, i)
+	}
+	for i := 1000; i < 1_000_000; i++ {
+		m.Delete(i - 1000)
+		m.Set(i, i)
+	}
+	if m.Len() != 1000 {
+		t.Fatalf("expected 1000 entries, got %d", m.Len())
+	}
+	// The entries only grow when more than 3/4 of them are live.
+	if c := m.EntriesCap(); c > 4*1000 {
+		t.Fatalf("entries have grown to %d", c)
+	}
+	want := 999_000
+	for k, v := range m.All() {
+		if k != want || v != want {
+			t.Fatalf("expected %d, got %d = %d", want, k, v)
+		}
+		want++
+	}
+}
+
+func BenchmarkOrderedSwiss(b *testing.B) {
+	keys := make([]string, 1<<14)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	o := hashblog.NewOrderedSwiss[string, int]()
+	s := hashblog.NewSwissTable[string, int]()
+	for i, k := range keys {
+		o.Set(k, i)
+		s.Set(k, i)
+	}
+
+	b.Run("get/i=SwissTable", func(b *testing.B) {
+		for i := range b.N {
+			s.Get(keys[i&(len(keys)-1)])
+		}
+	})
+	b.Run("get/i=OrderedSwiss", func(b *testing.B) {
+		for i := range b.N {
+			o.Get(keys[i&(len(keys)-1)])
+		}
+	})
+	b.Run("all/i=OrderedSwiss", func(b *testing.B) {
+		for range b.N {
+			for range o.All() {
+			}
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(keys)), "ns/entry")
+	})
+}
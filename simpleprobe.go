@@ -23,6 +23,11 @@ func (st *SimpleTableProbe[K, V]) Get(key K) (v V, ok bool) {
 	return v, false
 }
 
+// Clear removes everything from the table.
+func (st *SimpleTableProbe[K, V]) Clear() {
+	clear(st.entries[:])
+}
+
 func (st *SimpleTableProbe[K, V]) find(key K) (*entry[K, V], bool) {
 	h := hash(key)
 
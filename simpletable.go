@@ -27,6 +27,11 @@ func (st *SimpleTable[K, V]) Get(key K) (v V, ok bool) {
 	return v, false
 }
 
+// Clear removes everything from the table.
+func (st *SimpleTable[K, V]) Clear() {
+	clear(st.entries[:])
+}
+
 func (st *SimpleTable[K, V]) find(key K) (*entry[K, V], bool) {
 	index := hash(key) % simpleTableSize
 
@@ -0,0 +1,267 @@
+package hashblog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stats describes how full a table is, and how far lookups have to probe to
+// find the keys in it.
+type Stats struct {
+	// Entries is the number of keys in the table.
+	Entries int
+	// Capacity is the number of slots in the table.
+	Capacity int
+	// LoadFactor is Entries / Capacity.
+	LoadFactor float64
+	// ProbeUnit is "slot" for tables that probe slot by slot, and "group" for
+	// tables that probe a group at a time.
+	ProbeUnit string
+	// ProbeLengths[n] is the number of keys a lookup finds after visiting n
+	// slots or groups. ProbeLengths[0] is always zero.
+	ProbeLengths []int
+	// GroupOccupancy[n] is the number of groups with n slots in use. It's nil
+	// for tables without groups.
+	GroupOccupancy []int
+	// Tombstones is the number of slots marked as deleted.
+	Tombstones int
+}
+
+// MeanProbeLength is the average number of slots or groups a lookup visits to
+// find a key that is present.
+func (s *Stats) MeanProbeLength() float64 {
+	if s.Entries == 0 {
+		return 0
+	}
+	var total int
+	for n, count := range s.ProbeLengths {
+		total += n * count
+	}
+	return float64(total) / float64(s.Entries)
+}
+
+func (s *Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "entries: %d/%d (load %.3f), tombstones: %d\n", s.Entries, s.Capacity, s.LoadFactor, s.Tombstones)
+	fmt.Fprintf(&b, "probe length (%ss): mean %.3f\n", s.ProbeUnit, s.MeanProbeLength())
+	for n, count := range s.ProbeLengths {
+		if count != 0 {
+			fmt.Fprintf(&b, "  %4d: %d\n", n, count)
+		}
+	}
+	if s.GroupOccupancy != nil {
+		fmt.Fprintf(&b, "group occupancy:\n")
+		for n, count := range s.GroupOccupancy {
+			fmt.Fprintf(&b, "  %4d: %d\n", n, count)
+		}
+	}
+	return b.String()
+}
+
+// newStats starts Stats for a table with the given number of slots. groupSize
+// is zero for tables without groups.
+func newStats(capacity, groupSize int) Stats {
+	s := Stats{Capacity: capacity, ProbeUnit: "slot"}
+	if groupSize != 0 {
+		s.ProbeUnit = "group"
+		s.GroupOccupancy = make([]int, groupSize+1)
+	}
+	return s
+}
+
+// addProbe records a key that is found after visiting n slots or groups.
+func (s *Stats) addProbe(n int) {
+	for len(s.ProbeLengths) <= n {
+		s.ProbeLengths = append(s.ProbeLengths, 0)
+	}
+	s.ProbeLengths[n]++
+	s.Entries++
+}
+
+func (s *Stats) finish() {
+	s.LoadFactor = float64(s.Entries) / float64(s.Capacity)
+}
+
+// probeLength returns the number of positions a probe sequence starting from
+// h visits to reach pos. It works the same way as lookups do, using
+// makeProbeSeq.
+func probeLength(h, mask hashValue, pos int) int {
+	n := 1
+	for seq := makeProbeSeq(h, mask); seq.offset != hashValue(pos); seq = seq.next() {
+		n++
+	}
+	return n
+}
+
+// Stats walks the table and returns statistics about it. SimpleTable uses the
+// zero key to mark empty slots, so an entry with the zero key isn't counted.
+func (st *SimpleTable[K, V]) Stats() Stats {
+	s := newStats(simpleTableSize, 0)
+	var zero K
+	for i := range st.entries {
+		e := &st.entries[i]
+		if e.key == zero {
+			continue
+		}
+		// SimpleTable doesn't use probeSeq: it steps through the slots one
+		// at a time.
+		home := int(hash(e.key) % simpleTableSize)
+		s.addProbe((i-home+simpleTableSize)%simpleTableSize + 1)
+	}
+	s.finish()
+	return s
+}
+
+// Stats walks the table and returns statistics about it. An entry with the zero
+// key isn't counted.
+func (st *SimpleTableProbe[K, V]) Stats() Stats {
+	s := newStats(simpleTableSize, 0)
+	var zero K
+	for i := range st.entries {
+		e := &st.entries[i]
+		if e.key == zero {
+			continue
+		}
+		s.addProbe(probeLength(hash(e.key), simpleTableSize-1, i))
+	}
+	s.finish()
+	return s
+}
+
+// Stats walks the table and returns statistics about it. An entry with the zero
+// key isn't counted.
+func (m *GroupTable[K, V]) Stats() Stats {
+	s := newStats(simpleTableSize, groupSize)
+	var zero K
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		var used int
+		for i := range g {
+			if g[i].key == zero {
+				continue
+			}
+			used++
+			s.addProbe(probeLength(hash(g[i].key), groupTableSize-1, gi))
+		}
+		s.GroupOccupancy[used]++
+	}
+	s.finish()
+	return s
+}
+
+// Stats walks the table and returns statistics about it.
+func (m *GroupTableCtrl[K, V]) Stats() Stats {
+	s := newStats(simpleTableSize, groupSize)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		var used int
+		for i, ctrl := range g.ctrl {
+			switch {
+			case ctrl == ctrlDeleted:
+				s.Tombstones++
+			case ctrl < 0x80:
+				used++
+				s.addProbe(probeLength(hash(g.entries[i].key)>>7, groupTableSize-1, gi))
+			}
+		}
+		s.GroupOccupancy[used]++
+	}
+	s.finish()
+	return s
+}
+
+// Stats walks the table and returns statistics about it.
+func (m *SwissTable[K, V]) Stats() Stats {
+	s := newStats(simpleTableSize, groupSize)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		var used int
+		for i, ctrl := range g.ctrl {
+			switch {
+			case ctrl == ctrlDeleted:
+				s.Tombstones++
+			case ctrl < 0x80:
+				used++
+				s.addProbe(probeLength(hash(g.entries[i].key)>>7, groupTableSize-1, gi))
+			}
+		}
+		s.GroupOccupancy[used]++
+	}
+	s.finish()
+	return s
+}
+
+// Stats walks the table and returns statistics about it.
+func (m *OrderedSwiss[K, V]) Stats() Stats {
+	s := newStats(simpleTableSize, groupSize)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		var used int
+		for i, ctrl := range g.ctrl {
+			switch {
+			case ctrl == ctrlDeleted:
+				s.Tombstones++
+			case ctrl < 0x80:
+				used++
+				s.addProbe(probeLength(hash(m.entries[g.index[i]].key)>>7, groupTableSize-1, gi))
+			}
+		}
+		s.GroupOccupancy[used]++
+	}
+	s.finish()
+	return s
+}
+
+// concreteStats does the work of Stats for the tables that use concreteCtrl.
+// keyHash returns the hash of the key in slot i of group gi.
+func concreteStats(ctrl func(gi int) concreteCtrl, keyHash func(gi, i int) hashValue) Stats {
+	s := newStats(simpleTableSize, groupSize)
+	for gi := range groupTableSize {
+		c := ctrl(gi)
+		var used int
+		for i := range groupSize {
+			switch b := c.get(i); {
+			case b == ctrlDeleted:
+				s.Tombstones++
+			case b < 0x80:
+				used++
+				s.addProbe(probeLength(keyHash(gi, i)>>7, groupTableSize-1, gi))
+			}
+		}
+		s.GroupOccupancy[used]++
+	}
+	s.finish()
+	return s
+}
+
+// Stats walks the table and returns statistics about it.
+func (m *SwissConcrete) Stats() Stats {
+	return concreteStats(
+		func(gi int) concreteCtrl { return m.groups[gi].ctrl },
+		func(gi, i int) hashValue { return concreteHash(m.groups[gi].entries[i].key) },
+	)
+}
+
+// Stats walks the table and returns statistics about it.
+func (m *SwissArena) Stats() Stats {
+	return concreteStats(
+		func(gi int) concreteCtrl { return m.groups[gi].ctrl },
+		func(gi, i int) hashValue { return concreteHash(m.arena.get(m.groups[gi].entries[i].key)) },
+	)
+}
+
+// Stats walks the table and returns statistics about it.
+func (m *SwissUint64) Stats() Stats {
+	return concreteStats(
+		func(gi int) concreteCtrl { return m.groups[gi].ctrl },
+		func(gi, i int) hashValue { return intHash(m.groups[gi].entries[i].key) },
+	)
+}
+
+// Stats walks the table and returns statistics about it.
+func (m *SwissUint32) Stats() Stats {
+	return concreteStats(
+		func(gi int) concreteCtrl { return m.groups[gi].ctrl },
+		func(gi, i int) hashValue { return intHash(uint64(m.groups[gi].keys[i])) },
+	)
+}
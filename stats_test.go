@@ -0,0 +1,110 @@
+package hashblog_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+type statser interface {
+	mapper
+	Stats() hashblog.Stats
+}
+
+func TestStats(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		m         statser
+		numGroups int
+	}{
+		{"SimpleTable", hashblog.NewSimpleTable[string, int](), 0},
+		{"SimpleTableProbe", hashblog.NewSimpleTableProbe[string, int](), 0},
+		{"GroupTable", hashblog.NewGroupTable[string, int](), 4096},
+		{"GroupTableCtrl", hashblog.NewGroupTableCtrl[string, int](), 4096},
+		{"SwissTable", hashblog.NewSwissTable[string, int](), 4096},
+		{"SwissConcrete", hashblog.NewSwissConcrete(), 4096},
+		{"SwissArena", hashblog.NewSwissArena(), 4096},
+		{"OrderedSwiss", hashblog.NewOrderedSwiss[string, int](), 4096},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m := test.m
+			const numKeys = 20000
+			for i := range numKeys {
+				m.Set(strconv.Itoa(i), i)
+			}
+			wantEntries := numKeys
+			if d, ok := m.(deleter); ok {
+				for i := 0; i < numKeys; i += 4 {
+					d.Delete(strconv.Itoa(i))
+				}
+				wantEntries -= numKeys / 4
+			}
+
+			s := m.Stats()
+			if s.Entries != wantEntries {
+				t.Errorf("expected %d entries, got %d", wantEntries, s.Entries)
+			}
+			if s.Capacity != 32768 {
+				t.Errorf("expected capacity 32768, got %d", s.Capacity)
+			}
+			if want := float64(wantEntries) / 32768; s.LoadFactor != want {
+				t.Errorf("expected load factor %f, got %f", want, s.LoadFactor)
+			}
+
+			if s.ProbeLengths[0] != 0 {
+				t.Errorf("expected no keys with probe length 0, got %d", s.ProbeLengths[0])
+			}
+			var total int
+			for _, count := range s.ProbeLengths {
+				total += count
+			}
+			if total != wantEntries {
+				t.Errorf("probe length histogram has %d keys, expected %d", total, wantEntries)
+			}
+			if mean := s.MeanProbeLength(); mean < 1 {
+				t.Errorf("expected mean probe length at least 1, got %f", mean)
+			}
+
+			if test.numGroups == 0 {
+				if s.GroupOccupancy != nil {
+					t.Errorf("expected no group occupancy for table without groups")
+				}
+				return
+			}
+			var groups, used int
+			for n, count := range s.GroupOccupancy {
+				groups += count
+				used += n * count
+			}
+			if groups != test.numGroups {
+				t.Errorf("group occupancy covers %d groups, expected %d", groups, test.numGroups)
+			}
+			if used != wantEntries {
+				t.Errorf("group occupancy covers %d entries, expected %d", used, wantEntries)
+			}
+		})
+	}
+}
+
+func TestStatsTombstones(t *testing.T) {
+	// GroupTableCtrl always leaves a tombstone when deleting.
+	m := hashblog.NewGroupTableCtrl[string, int]()
+	for i := range 100 {
+		m.Set(strconv.Itoa(i), i)
+	}
+	for i := range 10 {
+		m.Delete(strconv.Itoa(i))
+	}
+	if s := m.Stats(); s.Tombstones != 10 || s.Entries != 90 {
+		t.Fatalf("expected 90 entries and 10 tombstones, got %d and %d", s.Entries, s.Tombstones)
+	}
+
+	// Reusing the slots removes the tombstones.
+	for i := range 10 {
+		m.Set(strconv.Itoa(i), i)
+	}
+	if s := m.Stats(); s.Tombstones != 0 || s.Entries != 100 {
+		t.Fatalf("expected 100 entries and no tombstones, got %d and %d", s.Entries, s.Tombstones)
+	}
+}
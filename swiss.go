@@ -16,7 +16,16 @@ import (
 // matching control bytes and empty slots, reducing the number of operations
 // needed to find these.
 type SwissTable[K comparable, V any] struct {
-	groups [groupTableSize]groupWithCtrl[K, V]
+	counters opCounters
+	groups   [groupTableSize]groupWithCtrl[K, V]
+	len      int
+	// tombstones is the number of deleted markers. See tombstones.
+	tombstones tombstones
+	// bloom is nil unless EnableBloomFilter is called.
+	bloom *bloomFilter
+	// keyCodec and valueCodec are nil unless SetCodecs is called.
+	keyCodec   Codec[K]
+	valueCodec Codec[V]
 }
 
 func NewSwissTable[K comparable, V any]() *SwissTable[K, V] {
@@ -28,6 +37,7 @@ func NewSwissTable[K comparable, V any]() *SwissTable[K, V] {
 }
 
 func (m *SwissTable[K, V]) Set(key K, value V) {
+	m.counters.set()
 	h := hash(key)
 
 	h1 := byte(h & 0x7F)
@@ -37,7 +47,13 @@ func (m *SwissTable[K, V]) Set(key K, value V) {
 	// compare against all control bytes in a group simultaneously.
 	h1Expanded := uint64(h1) * 0x0101010101010101
 
+	// The first empty or deleted slot we pass. If the key isn't in the table
+	// this is where it goes.
+	var slotGroup *groupWithCtrl[K, V]
+	var slot int
+
 	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		m.counters.probeGroup()
 		g := &m.groups[seq.offset]
 		// Find possible matches for this entry in the group. findMatches
 		// returns a bitmask where each byte with a matching control byte has
@@ -45,20 +61,39 @@ func (m *SwissTable[K, V]) Set(key K, value V) {
 		matches := g.ctrl.findMatches(h1Expanded)
 		for matches != 0 {
 			i := bits.TrailingZeros64(matches) / 8
+			m.counters.compareKey()
 			if e := &g.entries[i]; e.key == key {
+				m.counters.overwrite()
 				e.value = value
 				return
 			}
+			m.counters.falsePositive()
 			// Clear the lowest set bit and continue
 			matches &= matches - 1
 		}
+		if slotGroup == nil {
+			if avail := g.ctrl.findEmptyOrDeleted(); avail != 0 {
+				slotGroup, slot = g, bits.TrailingZeros64(avail)/8
+			}
+		}
 		// Check for empty slot in group. This returns a bitmask where each
 		// byte that is empty has its high bit set.
 		if empties := g.ctrl.findEmpty(); empties != 0 {
-			i := bits.TrailingZeros64(empties) / 8
 			// Empty slot - this means the key is not present in the table
-			g.entries[i] = entry[K, V]{key: key, value: value}
-			g.ctrl[i] = h1
+			m.counters.insert()
+			if m.bloom != nil {
+				m.bloom.add(h)
+			}
+			if m.tombstones.tooMany(m.len) {
+				// Rehashing moves everything, including the slot we found.
+				m.rehash()
+				m.insert(key, value, h, false)
+				return
+			}
+			m.tombstones.reused(slotGroup.ctrl[slot])
+			slotGroup.entries[slot] = entry[K, V]{key: key, value: value}
+			slotGroup.ctrl[slot] = h1
+			m.len++
 			return
 		}
 	}
@@ -68,8 +103,11 @@ func (m *SwissTable[K, V]) Get(key K) (v V, ok bool) {
 	if m == nil {
 		return v, false
 	}
-	h := hash(key)
+	return m.get(key, hash(key))
+}
 
+// get looks up key, given its hash h.
+func (m *SwissTable[K, V]) get(key K, h hashValue) (v V, ok bool) {
 	h1 := byte(h & 0x7F)
 	h2 := (h >> 7)
 
@@ -77,7 +115,13 @@ func (m *SwissTable[K, V]) Get(key K) (v V, ok bool) {
 	// compare against all control bytes in a group simultaneously.
 	h1Expanded := uint64(h1) * 0x0101010101010101
 
+	m.counters.get()
+	if m.bloom != nil && !m.bloom.mayContain(h) {
+		m.counters.miss()
+		return v, false
+	}
 	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		m.counters.probeGroup()
 		g := &m.groups[seq.offset]
 		// Find possible matches for this entry in the group. findMatches
 		// returns a bitmask where each byte with a matching control byte has
@@ -85,20 +129,92 @@ func (m *SwissTable[K, V]) Get(key K) (v V, ok bool) {
 		matches := g.ctrl.findMatches(h1Expanded)
 		for matches != 0 {
 			i := bits.TrailingZeros64(matches) / 8
+			m.counters.compareKey()
 			if e := &g.entries[i]; e.key == key {
+				m.counters.hit()
 				return e.value, true
 			}
+			m.counters.falsePositive()
 			// Clear the lowest set bit and continue
 			matches &= matches - 1
 		}
 		// Check for empty slot in the group. If there is an empty slot, the key
 		// is not present in the table.
 		if empties := g.ctrl.findEmpty(); empties != 0 {
+			m.counters.miss()
 			return v, false
 		}
 	}
 }
 
+// Delete removes key from the table. See GroupTableCtrl.Delete for why we
+// might need to leave a deleted marker rather than mark the slot empty.
+//
+// Here we look at the group as a whole rather than slot by slot. If the group
+// has an empty slot then no probe sequence has ever continued past it, and we
+// can mark the slot empty. Set rehashes if the markers pile up: see
+// tombstones.
+func (m *SwissTable[K, V]) Delete(key K) {
+	h := hash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101010101010101
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; e.key == key {
+				*e = entry[K, V]{}
+				m.tombstones.deleted(g.ctrl.delete(i))
+				m.len--
+				if m.bloom != nil && m.bloom.remove() {
+					m.rebuildBloom()
+				}
+				return
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return
+		}
+	}
+}
+
+// Clear removes everything from the table.
+func (m *SwissTable[K, V]) Clear() {
+	for i := range m.groups {
+		m.groups[i] = groupWithCtrl[K, V]{ctrl: groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}}
+	}
+	m.len, m.tombstones = 0, 0
+	if m.bloom != nil {
+		*m.bloom = bloomFilter{}
+	}
+}
+
+// rehash removes the deleted markers by taking every entry out and inserting
+// it again. See tombstones. The keys don't change, so the Bloom filter doesn't
+// either.
+func (m *SwissTable[K, V]) rehash() {
+	all := make([]entry[K, V], 0, m.len)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl < 0x80 {
+				all = append(all, g.entries[i])
+			}
+		}
+		*g = groupWithCtrl[K, V]{ctrl: groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}}
+	}
+	m.len, m.tombstones = 0, 0
+	for _, e := range all {
+		m.insert(e.key, e.value, hash(e.key), false)
+	}
+}
+
 func (gc groupCtrl) findMatches(h1Expanded uint64) uint64 {
 	// Find the entries where the control byte matches the bottom 7 bits of the
 	// hash (h1).
@@ -116,9 +232,28 @@ func (gc groupCtrl) findMatches(h1Expanded uint64) uint64 {
 }
 
 func (gc groupCtrl) findEmpty() uint64 {
+	// Empty (0x80) and deleted (0xFE) slots both have the top bit set. Deleted
+	// slots also have bit 1 set, so we shift that up to the top bit and use it
+	// to clear the top bit for deleted slots.
+	v := gc.toBitmask()
+	return (v &^ (v << 6)) & 0x8080808080808080
+}
+
+func (gc groupCtrl) findEmptyOrDeleted() uint64 {
 	return gc.toBitmask() & 0x8080808080808080
 }
 
+// delete marks slot i as deleted, or as empty if the group has an empty slot
+// already. It reports whether it left a deleted marker.
+func (gc *groupCtrl) delete(i int) (marked bool) {
+	if gc.findEmpty() != 0 {
+		gc[i] = 0x80
+		return false
+	}
+	gc[i] = ctrlDeleted
+	return true
+}
+
 func (gc groupCtrl) toBitmask() uint64 {
 	return *(*uint64)(unsafe.Pointer(&gc))
 }
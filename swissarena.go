@@ -0,0 +1,249 @@
+package hashblog
+
+import (
+	"math/bits"
+	"unsafe"
+)
+
+// SwissArena is SwissConcrete, except that instead of keeping each key as a Go
+// string it copies the key's bytes into an arena owned by the table.
+//
+// A string is a pointer to its bytes, so each key in SwissConcrete is a
+// separate object the GC has to find and mark, and the GC has to scan every
+// entry in the table to find them. SwissArena's entries hold the location of
+// the key in the arena rather than a pointer. The groups contain no pointers at
+// all, so the GC doesn't scan them, and the arena is a handful of big byte
+// slices rather than one object per key.
+//
+// The cost is an extra indirection when comparing keys. Overwriting a key
+// doesn't use more arena space, and deleting a key doesn't give any back
+// straight away. Instead, once the deleted keys take up more of the arena than
+// the live ones, Set rehashes the table and copies the live keys into a new
+// arena.
+type SwissArena struct {
+	groups [groupTableSize]arenaGroup
+	arena  stringArena
+	len    int
+	// tombstones is the number of deleted markers. See tombstones.
+	tombstones tombstones
+	// live and dead are the number of bytes in the arena used by keys in the
+	// table and by keys that have been deleted.
+	live, dead int
+}
+
+func NewSwissArena() *SwissArena {
+	m := &SwissArena{}
+	for i := range m.groups {
+		m.groups[i].ctrl = concreteCtrl(0x8080_8080_8080_8080)
+	}
+	return m
+}
+
+func (m *SwissArena) Set(key string, value int) {
+	h := concreteHash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101_0101_0101_0101
+
+	var slotGroup *arenaGroup
+	var slot int
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; m.arena.equal(e.key, key) {
+				e.value = value
+				return
+			}
+			matches &= matches - 1
+		}
+		if slotGroup == nil {
+			if avail := g.ctrl.findEmptyOrDeleted(); avail != 0 {
+				slotGroup, slot = g, bits.TrailingZeros64(avail)/8
+			}
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			if m.tombstones.tooMany(m.len) || m.dead > max(m.live, arenaChunkSize) {
+				// Rehashing moves everything, including the slot we found,
+				// and replaces the arena, so we add the key afterwards.
+				m.rehash()
+				m.insert(arenaEntry{key: m.addKey(key), value: value}, h)
+				return
+			}
+			// Only copy the key into the arena once we know it's new.
+			e := arenaEntry{key: m.addKey(key), value: value}
+			m.tombstones.reused(slotGroup.ctrl.get(slot))
+			slotGroup.entries[slot] = e
+			slotGroup.ctrl.set(slot, h1)
+			m.len++
+			return
+		}
+	}
+}
+
+func (m *SwissArena) Get(key string) (v int, ok bool) {
+	if m == nil {
+		return v, false
+	}
+	if g, i := m.find(key); g != nil {
+		return g.entries[i].value, true
+	}
+	return v, false
+}
+
+// Delete removes key from the table. The key's bytes stay in the arena until
+// Set next rehashes the table.
+func (m *SwissArena) Delete(key string) {
+	g, i := m.find(key)
+	if g == nil {
+		return
+	}
+	e := &g.entries[i]
+	m.live -= int(e.key.length)
+	m.dead += int(e.key.length)
+	*e = arenaEntry{}
+	m.tombstones.deleted(g.ctrl.delete(i))
+	m.len--
+}
+
+// find returns the group and slot holding key, or a nil group if key isn't
+// present.
+func (m *SwissArena) find(key string) (*arenaGroup, int) {
+	h := concreteHash(key)
+	h1Expanded := uint64(h&0x7F) * 0x0101_0101_0101_0101
+
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if m.arena.equal(g.entries[i].key, key) {
+				return g, i
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return nil, 0
+		}
+	}
+}
+
+// Clear removes everything from the table, and releases the arena.
+func (m *SwissArena) Clear() {
+	for i := range m.groups {
+		m.groups[i] = arenaGroup{ctrl: concreteCtrl(0x8080_8080_8080_8080)}
+	}
+	m.arena = stringArena{}
+	m.len, m.tombstones = 0, 0
+	m.live, m.dead = 0, 0
+}
+
+// addKey copies key into the arena.
+func (m *SwissArena) addKey(key string) arenaRef {
+	m.live += len(key)
+	return m.arena.add(key)
+}
+
+// rehash removes the deleted markers by taking every entry out and inserting
+// it again. See tombstones. It also copies the live keys into a new arena,
+// leaving the deleted ones behind.
+func (m *SwissArena) rehash() {
+	all := make([]arenaEntry, 0, m.len)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i := range groupSize {
+			if g.ctrl.get(i) < 0x80 {
+				all = append(all, g.entries[i])
+			}
+		}
+		*g = arenaGroup{ctrl: concreteCtrl(0x8080_8080_8080_8080)}
+	}
+	m.len, m.tombstones = 0, 0
+	old := m.arena
+	m.arena = stringArena{}
+	m.live, m.dead = 0, 0
+	for _, e := range all {
+		key := old.get(e.key)
+		e.key = m.addKey(key)
+		m.insert(e, concreteHash(key))
+	}
+}
+
+// insert adds e, whose key has hash h and isn't already in the table, to the
+// first empty slot on its probe sequence.
+func (m *SwissArena) insert(e arenaEntry, h hashValue) {
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			i := bits.TrailingZeros64(empties) / 8
+			g.entries[i] = e
+			g.ctrl.set(i, byte(h&0x7F))
+			m.len++
+			return
+		}
+	}
+}
+
+type arenaGroup struct {
+	ctrl    concreteCtrl
+	entries [groupSize]arenaEntry
+}
+
+type arenaEntry struct {
+	key   arenaRef
+	value int
+}
+
+// arenaChunkSize is the size of each chunk of a stringArena. Strings longer
+// than this get a chunk of their own.
+const arenaChunkSize = 64 << 10
+
+// stringArena stores strings in a list of large byte slices. We never move
+// anything once it is added, so we can't just use one big slice and append to
+// it.
+type stringArena struct {
+	chunks [][]byte
+}
+
+// arenaRef is the location of a string in a stringArena.
+type arenaRef struct {
+	chunk  uint32
+	offset uint32
+	length uint32
+}
+
+// add copies s into the arena and returns its location.
+func (a *stringArena) add(s string) arenaRef {
+	if len(a.chunks) == 0 {
+		a.chunks = append(a.chunks, make([]byte, 0, max(arenaChunkSize, len(s))))
+	}
+	last := &a.chunks[len(a.chunks)-1]
+	if cap(*last)-len(*last) < len(s) {
+		a.chunks = append(a.chunks, make([]byte, 0, max(arenaChunkSize, len(s))))
+		last = &a.chunks[len(a.chunks)-1]
+	}
+
+	ref := arenaRef{
+		chunk:  uint32(len(a.chunks) - 1),
+		offset: uint32(len(*last)),
+		length: uint32(len(s)),
+	}
+	*last = append(*last, s...)
+	return ref
+}
+
+// get returns the string at r. The string shares memory with the arena, so
+// it's only valid as long as the arena is.
+func (a *stringArena) get(r arenaRef) string {
+	b := a.chunks[r.chunk][r.offset : r.offset+r.length]
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// equal reports whether the string at r is s.
+func (a *stringArena) equal(r arenaRef, s string) bool {
+	return int(r.length) == len(s) && a.get(r) == s
+}
@@ -0,0 +1,11 @@
+package hashblog
+
+// ArenaBytes returns the number of bytes the table's arena has allocated, so
+// tests can check deleted keys are given back.
+func (m *SwissArena) ArenaBytes() int {
+	var n int
+	for _, c := range m.arena.chunks {
+		n += cap(c)
+	}
+	return n
+}
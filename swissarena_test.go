@@ -0,0 +1,37 @@
+package hashblog_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+// TestSwissArenaReclaim deletes and adds keys for a long time with only a few
+// in the table at once. Few of the deletes leave deleted markers, so it's the
+// space the deleted keys take up in the arena that makes the table rehash.
+func TestSwissArenaReclaim(t *testing.T) {
+	const live = 1000
+	m := hashblog.NewSwissArena()
+	key := func(i int) string { return "a fairly long key so we get through the arena quickly " + strconv.Itoa(i) }
+	for i := range live {
+		m.Set(key(i), i)
+	}
+	for i := live; i < 200_000; i++ {
+		m.Delete(key(i - live))
+		m.Set(key(i), i)
+	}
+	for i := 200_000 - live; i < 200_000; i++ {
+		if v, ok := m.Get(key(i)); !ok || v != i {
+			t.Fatalf("Get(%d) = %d, %t", i, v, ok)
+		}
+	}
+	if _, ok := m.Get(key(0)); ok {
+		t.Fatal("found a deleted key")
+	}
+	// The live keys take about 60KB. Without reclaiming the deleted ones
+	// the arena would be over 12MB.
+	if n := m.ArenaBytes(); n > 512<<10 {
+		t.Fatalf("arena has grown to %d bytes", n)
+	}
+}
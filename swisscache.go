@@ -0,0 +1,258 @@
+package hashblog
+
+import "math/bits"
+
+// SwissCacheMaxEntries is the largest entry budget a SwissCache can have. We
+// keep a quarter of the table free so there are always plenty of empty slots to
+// end probe sequences.
+const SwissCacheMaxEntries = simpleTableSize * 3 / 4
+
+// SwissCache is a fixed-size cache that evicts entries using the CLOCK
+// algorithm, built on the SwissTable layout.
+//
+// An LRU cache keeps its entries on a linked list in order of use, which costs
+// two pointers per entry and some pointer chasing on every hit. CLOCK
+// approximates LRU with a single reference bit per entry. Each hit sets the
+// entry's bit. To evict, a "hand" sweeps round the table: it clears any bits
+// that are set, giving those entries a second chance, and evicts the first
+// entry whose bit is already clear. So an entry is only evicted if it hasn't
+// been used since the hand last went past.
+//
+// We keep the reference bits for each group in a byte next to its control
+// bytes, so the hand can skip over whole groups of recently-used entries at a
+// time.
+//
+// Unlike the other tables, the number of groups depends on the budget: it is
+// the smallest power of two that keeps a quarter of the slots free. The hand
+// has to go past all the groups to find a victim, so with the full 4096 groups
+// a small cache would walk half the table on every eviction.
+type SwissCache[K comparable, V any] struct {
+	groups []cacheGroup[K, V]
+	budget int
+	len    int
+	// tombstones is the number of deleted markers. See tombstones.
+	tombstones tombstones
+	// handGroup and handSlot are the position of the CLOCK hand.
+	handGroup int
+	handSlot  int
+	onEvict   func(key K, value V)
+
+	hits, misses, evictions uint64
+}
+
+type cacheGroup[K comparable, V any] struct {
+	ctrl groupCtrl
+	// ref has bit i set if slot i has been used since the hand last passed.
+	ref     uint8
+	entries [groupSize]entry[K, V]
+}
+
+// NewSwissCache returns a cache that holds at most budget entries. If onEvict
+// isn't nil it is called with each entry the cache evicts to make room for a
+// new one. onEvict must not change the cache. NewSwissCache panics if budget is
+// less than 1 or more than SwissCacheMaxEntries.
+func NewSwissCache[K comparable, V any](budget int, onEvict func(key K, value V)) *SwissCache[K, V] {
+	if budget < 1 || budget > SwissCacheMaxEntries {
+		panic("hashblog: SwissCache budget out of range")
+	}
+	numGroups := 1
+	for numGroups*groupSize*3/4 < budget {
+		numGroups *= 2
+	}
+	c := &SwissCache[K, V]{groups: make([]cacheGroup[K, V], numGroups), budget: budget, onEvict: onEvict}
+	for i := range c.groups {
+		c.groups[i].ctrl = groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}
+	}
+	return c
+}
+
+// Get returns the value for key, and marks it as recently used.
+func (c *SwissCache[K, V]) Get(key K) (v V, ok bool) {
+	g, i := c.find(key)
+	if g == nil {
+		c.misses++
+		return v, false
+	}
+	c.hits++
+	g.ref |= 1 << i
+	return g.entries[i].value, true
+}
+
+// Peek returns the value for key without marking it as used or counting a hit
+// or miss.
+func (c *SwissCache[K, V]) Peek(key K) (v V, ok bool) {
+	g, i := c.find(key)
+	if g == nil {
+		return v, false
+	}
+	return g.entries[i].value, true
+}
+
+// Set sets the value for key and marks it as recently used. If key is new and
+// the cache is full, Set evicts an entry to make room.
+func (c *SwissCache[K, V]) Set(key K, value V) {
+	h := hash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101010101010101
+
+	for seq := makeProbeSeq(h2, hashValue(len(c.groups)-1)); ; seq = seq.next() {
+		g := &c.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; e.key == key {
+				e.value = value
+				g.ref |= 1 << i
+				return
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			break
+		}
+	}
+
+	// The key is new. Evicting may change which slot is the first free one
+	// on the probe sequence, so we find it after evicting.
+	if c.len == c.budget {
+		c.evict()
+	}
+	if c.tombstones.tooManyIn(c.len, len(c.groups)*groupSize) {
+		c.rehash()
+	}
+	c.insert(key, value, h, true)
+}
+
+// Len returns the number of entries in the cache.
+func (c *SwissCache[K, V]) Len() int { return c.len }
+
+// Hits returns the number of calls to Get that found their key.
+func (c *SwissCache[K, V]) Hits() uint64 { return c.hits }
+
+// Misses returns the number of calls to Get that didn't find their key.
+func (c *SwissCache[K, V]) Misses() uint64 { return c.misses }
+
+// Evictions returns the number of entries evicted to make room for others.
+func (c *SwissCache[K, V]) Evictions() uint64 { return c.evictions }
+
+// evict moves the hand round the table until it finds an entry that hasn't
+// been used since the hand last passed, clearing the reference bits of the
+// entries that have, then removes that entry.
+func (c *SwissCache[K, V]) evict() {
+	for {
+		g := &c.groups[c.handGroup]
+		// The slots at or after the hand that are in use and haven't been
+		// used recently.
+		passed := uint8(0xFF) << c.handSlot
+		if victims := g.used() &^ g.ref & passed; victims != 0 {
+			i := bits.TrailingZeros8(victims)
+			c.handSlot = i + 1
+			if c.handSlot == groupSize {
+				c.nextGroup()
+			}
+			c.remove(g, i)
+			return
+		}
+		g.ref &^= passed
+		c.nextGroup()
+	}
+}
+
+func (c *SwissCache[K, V]) nextGroup() {
+	c.handGroup = (c.handGroup + 1) & (len(c.groups) - 1)
+	c.handSlot = 0
+}
+
+// remove evicts the entry in slot i of g.
+func (c *SwissCache[K, V]) remove(g *cacheGroup[K, V], i int) {
+	e := g.entries[i]
+	g.entries[i] = entry[K, V]{}
+	g.ref &^= 1 << i
+	c.tombstones.deleted(g.ctrl.delete(i))
+	c.len--
+	c.evictions++
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.value)
+	}
+}
+
+// insert adds key, which has hash h, to the first empty or deleted slot on its
+// probe sequence. key must not already be in the cache. ref is whether to mark
+// the entry as used. Set marks new entries as used, so they survive at least
+// until the hand next passes them.
+func (c *SwissCache[K, V]) insert(key K, value V, h hashValue, ref bool) {
+	for seq := makeProbeSeq(h>>7, hashValue(len(c.groups)-1)); ; seq = seq.next() {
+		g := &c.groups[seq.offset]
+		if avail := g.ctrl.findEmptyOrDeleted(); avail != 0 {
+			i := bits.TrailingZeros64(avail) / 8
+			c.tombstones.reused(g.ctrl[i])
+			g.entries[i] = entry[K, V]{key: key, value: value}
+			g.ctrl[i] = byte(h & 0x7F)
+			if ref {
+				g.ref |= 1 << i
+			}
+			c.len++
+			return
+		}
+	}
+}
+
+// rehash removes the deleted markers by taking every entry out and inserting
+// it again, keeping its reference bit. See tombstones.
+func (c *SwissCache[K, V]) rehash() {
+	type saved struct {
+		entry[K, V]
+		ref bool
+	}
+	all := make([]saved, 0, c.len)
+	for gi := range c.groups {
+		g := &c.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl < 0x80 {
+				all = append(all, saved{entry: g.entries[i], ref: g.ref&(1<<i) != 0})
+			}
+		}
+		*g = cacheGroup[K, V]{ctrl: groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}}
+	}
+	c.len, c.tombstones = 0, 0
+
+	for _, s := range all {
+		c.insert(s.key, s.value, hash(s.key), s.ref)
+	}
+}
+
+// find returns the group and slot holding key, or a nil group if key isn't
+// present.
+func (c *SwissCache[K, V]) find(key K) (*cacheGroup[K, V], int) {
+	h := hash(key)
+	h1Expanded := uint64(h&0x7F) * 0x0101010101010101
+
+	for seq := makeProbeSeq(h>>7, hashValue(len(c.groups)-1)); ; seq = seq.next() {
+		g := &c.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if g.entries[i].key == key {
+				return g, i
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return nil, 0
+		}
+	}
+}
+
+// used returns a bitmask with bit i set if slot i is in use.
+func (g *cacheGroup[K, V]) used() uint8 {
+	var used uint8
+	for i, ctrl := range g.ctrl {
+		if ctrl < 0x80 {
+			used |= 1 << i
+		}
+	}
+	return used
+}
@@ -0,0 +1,227 @@
+package hashblog_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+func TestSwissCache(t *testing.T) {
+	evicted := make(map[int]int)
+	c := hashblog.NewSwissCache(1000, func(key, value int) {
+		if _, ok := evicted[key]; ok {
+			t.Fatalf("key %d evicted twice", key)
+		}
+		evicted[key] = value
+	})
+
+	for i := range 1000 {
+		c.Set(i, i)
+	}
+	if c.Len() != 1000 || len(evicted) != 0 {
+		t.Fatalf("expected 1000 entries and no evictions, got %d and %d", c.Len(), len(evicted))
+	}
+
+	// Overwriting doesn't evict.
+	c.Set(0, -1)
+	if v, ok := c.Peek(0); !ok || v != -1 {
+		t.Fatalf("expected 0 to have value -1, got %d, %t", v, ok)
+	}
+	if len(evicted) != 0 {
+		t.Fatalf("overwriting evicted %d entries", len(evicted))
+	}
+
+	for i := 1000; i < 5000; i++ {
+		c.Set(i, i)
+	}
+	if c.Len() != 1000 || len(evicted) != 4000 || c.Evictions() != 4000 {
+		t.Fatalf("expected 1000 entries and 4000 evictions, got %d, %d and %d", c.Len(), len(evicted), c.Evictions())
+	}
+	// Every key is either in the cache or was evicted, with the value it had.
+	for i := range 5000 {
+		v, inCache := c.Peek(i)
+		ev, wasEvicted := evicted[i]
+		if inCache == wasEvicted {
+			t.Fatalf("key %d: in cache %t, evicted %t", i, inCache, wasEvicted)
+		}
+		if !inCache {
+			v = ev
+		}
+		if want := i; i == 0 && v != -1 || i != 0 && v != want {
+			t.Fatalf("key %d has value %d", i, v)
+		}
+	}
+
+	if c.Hits() != 0 || c.Misses() != 0 {
+		t.Fatalf("Peek counted %d hits and %d misses", c.Hits(), c.Misses())
+	}
+	for i := range 5000 {
+		c.Get(i)
+	}
+	if c.Hits() != 1000 || c.Misses() != 4000 {
+		t.Fatalf("expected 1000 hits and 4000 misses, got %d and %d", c.Hits(), c.Misses())
+	}
+}
+
+func TestSwissCacheBudget(t *testing.T) {
+	for _, budget := range []int{0, -1, hashblog.SwissCacheMaxEntries + 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic for budget %d", budget)
+				}
+			}()
+			hashblog.NewSwissCache[int, int](budget, nil)
+		}()
+	}
+}
+
+// TestSwissCacheChurn runs the cache at its largest size for a long time, so it
+// has to clear out deleted markers to keep working.
+func TestSwissCacheChurn(t *testing.T) {
+	c := hashblog.NewSwissCache[string, int](hashblog.SwissCacheMaxEntries, nil)
+	for i := range 20 * hashblog.SwissCacheMaxEntries {
+		c.Set(strconv.Itoa(i), i)
+		if _, ok := c.Get(strconv.Itoa(-i - 1)); ok {
+			t.Fatalf("found key that was never added")
+		}
+	}
+	if c.Len() != hashblog.SwissCacheMaxEntries {
+		t.Fatalf("expected a full cache, got %d entries", c.Len())
+	}
+	// The most recently added key is always there.
+	key := strconv.Itoa(20*hashblog.SwissCacheMaxEntries - 1)
+	if _, ok := c.Get(key); !ok {
+		t.Fatalf("most recent key is missing")
+	}
+}
+
+// TestSwissCacheSmall checks caches with budgets around the sizes where they
+// get more groups.
+func TestSwissCacheSmall(t *testing.T) {
+	for _, budget := range []int{1, 6, 7, 12, 13} {
+		c := hashblog.NewSwissCache[int, int](budget, nil)
+		for i := range 1000 {
+			c.Set(i, i)
+			if v, ok := c.Peek(i); !ok || v != i {
+				t.Fatalf("budget %d: key %d missing straight after Set", budget, i)
+			}
+			if _, ok := c.Get(-1); ok {
+				t.Fatalf("budget %d: found key that was never added", budget)
+			}
+		}
+		if c.Len() != budget {
+			t.Fatalf("budget %d: expected a full cache, got %d entries", budget, c.Len())
+		}
+	}
+}
+
+// TestSwissCacheLooping checks that a loop over fewer keys than the budget
+// always hits once the keys are loaded. An LRU cache would do the same.
+func TestSwissCacheLooping(t *testing.T) {
+	c := hashblog.NewSwissCache[int, int](1000, nil)
+	for range 10 {
+		for i := range 900 {
+			if _, ok := c.Get(i); !ok {
+				c.Set(i, i)
+			}
+		}
+	}
+	if c.Misses() != 900 {
+		t.Fatalf("expected only the first pass to miss, got %d misses", c.Misses())
+	}
+}
+
+// TestSwissCacheScan checks that keys that are used often survive a scan
+// through lots of keys that are used once. An LRU cache would keep all the hot
+// keys, as each is used again well before a budget's worth of other keys go by.
+// CLOCK loses the odd one: the hand can go round faster than that once it has
+// cleared the reference bits of the scan keys.
+func TestSwissCacheScan(t *testing.T) {
+	const budget = 1000
+	const hot = budget / 4
+	c := hashblog.NewSwissCache[int, int](budget, nil)
+	for i := range hot {
+		c.Set(i, i)
+	}
+
+	// After each scan key we use one hot key, so each hot key is used once
+	// every 2*hot operations, well within the budget.
+	var hotMisses int
+	for i := range 20 * budget {
+		c.Set(hot+i, i)
+		if _, ok := c.Get(i % hot); !ok {
+			hotMisses++
+			c.Set(i%hot, i)
+		}
+	}
+	if hotMisses > 20*budget/100 {
+		t.Fatalf("expected hot keys to stay in the cache, but missed %d times in %d", hotMisses, 20*budget)
+	}
+}
+
+// TestSwissCacheRecency checks that recently added keys are more likely to be
+// in the cache than old ones. CLOCK's hand goes round the table in hash order
+// rather than the order keys were added, so this is only approximately LRU.
+func TestSwissCacheRecency(t *testing.T) {
+	const budget = 4000
+	c := hashblog.NewSwissCache[int, int](budget, nil)
+	const n = 10 * budget
+	for i := range n {
+		c.Set(i, i)
+	}
+	present := func(start, end int) float64 {
+		var found int
+		for i := start; i < end; i++ {
+			if _, ok := c.Peek(i); ok {
+				found++
+			}
+		}
+		return float64(found) / float64(end-start)
+	}
+
+	newest, older, oldest := present(n-budget/4, n), present(n-2*budget, n-budget), present(0, n-2*budget)
+	t.Logf("newest quarter budget %.2f, previous budget %.2f, older %.2f", newest, older, oldest)
+	if newest < 0.9 {
+		t.Errorf("expected at least 90%% of the newest keys, got %.2f", newest)
+	}
+	if older > 0.5 {
+		t.Errorf("expected at most half of the keys from one budget back, got %.2f", older)
+	}
+	if oldest > 0.05 {
+		t.Errorf("expected almost none of the oldest keys, got %.2f", oldest)
+	}
+}
+
+func BenchmarkSwissCacheGet(b *testing.B) {
+	c := hashblog.NewSwissCache[string, int](16000, nil)
+	keys := make([]string, 16000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		c.Set(keys[i], i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		for _, key := range keys {
+			c.Get(key)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(len(keys)), "ns/op")
+}
+
+// BenchmarkSwissCacheSetMiss sets a new key every time, so once the cache is
+// full every Set evicts.
+func BenchmarkSwissCacheSetMiss(b *testing.B) {
+	for _, budget := range []int{1, 16, 1000, hashblog.SwissCacheMaxEntries} {
+		b.Run(strconv.Itoa(budget), func(b *testing.B) {
+			c := hashblog.NewSwissCache[int, int](budget, nil)
+			var i int
+			for b.Loop() {
+				c.Set(i, i)
+				i++
+			}
+		})
+	}
+}
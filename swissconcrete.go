@@ -16,7 +16,13 @@ import (
 // matching control bytes and empty slots, reducing the number of operations
 // needed to find these.
 type SwissConcrete struct {
-	groups [groupTableSize]concreteGroupWithCtrl
+	counters opCounters
+	groups   [groupTableSize]concreteGroupWithCtrl
+	len      int
+	// tombstones is the number of deleted markers. See tombstones.
+	tombstones tombstones
+	// bloom is nil unless EnableBloomFilter is called.
+	bloom *bloomFilter
 }
 
 func NewSwissConcrete() *SwissConcrete {
@@ -28,6 +34,7 @@ func NewSwissConcrete() *SwissConcrete {
 }
 
 func (m *SwissConcrete) Set(key string, value int) {
+	m.counters.set()
 	h := concreteHash(key)
 
 	h1 := byte(h & 0x7F)
@@ -37,7 +44,13 @@ func (m *SwissConcrete) Set(key string, value int) {
 	// compare against all control bytes in a group simultaneously.
 	h1Expanded := uint64(h1) * 0x0101010101010101
 
+	// The first empty or deleted slot we pass. If the key isn't in the table
+	// this is where it goes.
+	var slotGroup *concreteGroupWithCtrl
+	var slot int
+
 	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		m.counters.probeGroup()
 		g := &m.groups[seq.offset]
 		// Find possible matches for this entry in the group. findMatches
 		// returns a bitmask where each byte with a matching control byte has
@@ -45,21 +58,39 @@ func (m *SwissConcrete) Set(key string, value int) {
 		matches := g.ctrl.findMatches(h1Expanded)
 		for matches != 0 {
 			i := bits.TrailingZeros64(matches) / 8
+			m.counters.compareKey()
 			if e := &g.entries[i]; e.key == key {
+				m.counters.overwrite()
 				e.value = value
 				return
 			}
+			m.counters.falsePositive()
 			// Clear the lowest set bit and continue
 			matches &= matches - 1
 		}
+		if slotGroup == nil {
+			if avail := g.ctrl.findEmptyOrDeleted(); avail != 0 {
+				slotGroup, slot = g, bits.TrailingZeros64(avail)/8
+			}
+		}
 		// Check for empty slot in group. This returns a bitmask where each
 		// byte that is empty has its high bit set.
 		if empties := g.ctrl.findEmpty(); empties != 0 {
-			i := bits.TrailingZeros64(empties) / 8
 			// Empty slot - this means the key is not present in the table
-			g.entries[i] = concreteEntry{key: key, value: value}
-			g.ctrl.set(i, h1)
-			// g.ctrl[i] = h1
+			m.counters.insert()
+			if m.bloom != nil {
+				m.bloom.add(h)
+			}
+			if m.tombstones.tooMany(m.len) {
+				// Rehashing moves everything, including the slot we found.
+				m.rehash()
+				m.insert(key, value, h)
+				return
+			}
+			m.tombstones.reused(slotGroup.ctrl.get(slot))
+			slotGroup.entries[slot] = concreteEntry{key: key, value: value}
+			slotGroup.ctrl.set(slot, h1)
+			m.len++
 			return
 		}
 	}
@@ -69,8 +100,11 @@ func (m *SwissConcrete) Get(key string) (v int, ok bool) {
 	if m == nil {
 		return v, false
 	}
-	h := concreteHash(key)
+	return m.get(key, concreteHash(key))
+}
 
+// get looks up key, given its hash h.
+func (m *SwissConcrete) get(key string, h hashValue) (v int, ok bool) {
 	h1 := byte(h & 0x7F)
 	h2 := (h >> 7)
 
@@ -78,7 +112,13 @@ func (m *SwissConcrete) Get(key string) (v int, ok bool) {
 	// compare against all control bytes in a group simultaneously.
 	h1Expanded := uint64(h1) * 0x0101_0101_0101_0101
 
+	m.counters.get()
+	if m.bloom != nil && !m.bloom.mayContain(h) {
+		m.counters.miss()
+		return v, false
+	}
 	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		m.counters.probeGroup()
 		g := &m.groups[seq.offset]
 		// Find possible matches for this entry in the group. findMatches
 		// returns a bitmask where each byte with a matching control byte has
@@ -86,9 +126,12 @@ func (m *SwissConcrete) Get(key string) (v int, ok bool) {
 		matches := g.ctrl.findMatches(h1Expanded)
 		for matches != 0 {
 			i := bits.TrailingZeros64(matches) / 8
+			m.counters.compareKey()
 			if e := &g.entries[i]; e.key == key {
+				m.counters.hit()
 				return e.value, true
 			}
+			m.counters.falsePositive()
 			// Clear the lowest set bit and continue
 			matches &= matches - 1
 		}
@@ -96,11 +139,88 @@ func (m *SwissConcrete) Get(key string) (v int, ok bool) {
 		// is not present in the table.
 		empties := g.ctrl.findEmpty()
 		if empties != 0 {
+			m.counters.miss()
 			return v, false
 		}
 	}
 }
 
+// Delete removes key from the table. See SwissTable.Delete for how this works.
+func (m *SwissConcrete) Delete(key string) {
+	h := concreteHash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101_0101_0101_0101
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; e.key == key {
+				*e = concreteEntry{}
+				m.tombstones.deleted(g.ctrl.delete(i))
+				m.len--
+				if m.bloom != nil && m.bloom.remove() {
+					m.rebuildBloom()
+				}
+				return
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return
+		}
+	}
+}
+
+// Clear removes everything from the table.
+func (m *SwissConcrete) Clear() {
+	for i := range m.groups {
+		m.groups[i] = concreteGroupWithCtrl{ctrl: concreteCtrl(0x8080_8080_8080_8080)}
+	}
+	m.len, m.tombstones = 0, 0
+	if m.bloom != nil {
+		*m.bloom = bloomFilter{}
+	}
+}
+
+// rehash removes the deleted markers by taking every entry out and inserting
+// it again. See tombstones.
+func (m *SwissConcrete) rehash() {
+	all := make([]concreteEntry, 0, m.len)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i := range groupSize {
+			if g.ctrl.get(i) < 0x80 {
+				all = append(all, g.entries[i])
+			}
+		}
+		*g = concreteGroupWithCtrl{ctrl: concreteCtrl(0x8080_8080_8080_8080)}
+	}
+	m.len, m.tombstones = 0, 0
+	for _, e := range all {
+		m.insert(e.key, e.value, concreteHash(e.key))
+	}
+}
+
+// insert adds key, which has hash h and isn't already in the table, to the
+// first empty slot on its probe sequence.
+func (m *SwissConcrete) insert(key string, value int, h hashValue) {
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			i := bits.TrailingZeros64(empties) / 8
+			g.entries[i] = concreteEntry{key: key, value: value}
+			g.ctrl.set(i, byte(h&0x7F))
+			m.len++
+			return
+		}
+	}
+}
+
 type concreteGroupWithCtrl struct {
 	ctrl    concreteCtrl
 	entries [groupSize]concreteEntry
@@ -114,13 +234,33 @@ func (gc concreteCtrl) findMatches(h1Expanded uint64) uint64 {
 }
 
 func (gc concreteCtrl) findEmpty() uint64 {
+	// See groupCtrl.findEmpty.
+	return (uint64(gc) &^ (uint64(gc) << 6)) & 0x8080_8080_8080_8080
+}
+
+func (gc concreteCtrl) findEmptyOrDeleted() uint64 {
 	return (uint64(gc) & 0x8080_8080_8080_8080)
 }
 
+func (gc concreteCtrl) get(i int) byte {
+	return (*(*[8]byte)(unsafe.Pointer(&gc)))[i]
+}
+
 func (gc *concreteCtrl) set(i int, v byte) {
 	(*(*[8]byte)(unsafe.Pointer(gc)))[i] = v
 }
 
+// delete marks slot i as deleted, or as empty if the group has an empty slot
+// already. It reports whether it left a deleted marker.
+func (gc *concreteCtrl) delete(i int) (marked bool) {
+	if gc.findEmpty() != 0 {
+		gc.set(i, 0x80)
+		return false
+	}
+	gc.set(i, ctrlDeleted)
+	return true
+}
+
 type concreteEntry struct {
 	key   string
 	value int
@@ -0,0 +1,124 @@
+package swissfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Build writes a file containing the keys and values from seq to w.
+//
+// The records are written as seq produces them, so Build doesn't keep the keys
+// and values, and seq may reuse their memory. It does keep a hash and offset
+// for each record, 16 bytes each, to build the index at the end.
+//
+// If a key appears more than once, Get finds the value from the first
+// occurrence. Keys and values must each be shorter than 4GB.
+//
+// The index is sized so it's at most 7/8 full, so a missing key's probe
+// sequence always finds an empty slot quickly.
+func Build(w io.Writer, seq iter.Seq2[[]byte, []byte]) error {
+	bw := &checksumWriter{w: bufio.NewWriterSize(w, 64<<10)}
+	bw.write([]byte{magic[0], magic[1], magic[2], magic[3], version, 0, 0, 0})
+
+	seed := rand.Uint64()
+	type slot struct {
+		hash, offset uint64
+	}
+	var slots []slot
+	var lengths [recordHeaderSize]byte
+	for key, value := range seq {
+		if uint64(len(key)) > math.MaxUint32 || uint64(len(value)) > math.MaxUint32 {
+			return fmt.Errorf("record %d is too large: key is %d bytes and value %d bytes", len(slots), len(key), len(value))
+		}
+		slots = append(slots, slot{hash: keyHash(seed, key), offset: bw.n})
+		binary.LittleEndian.PutUint32(lengths[:], uint32(len(key)))
+		binary.LittleEndian.PutUint32(lengths[4:], uint32(len(value)))
+		bw.write(lengths[:])
+		bw.write(key)
+		bw.write(value)
+		if bw.err != nil {
+			return bw.err
+		}
+	}
+
+	// Pad so the index starts on an 8 byte boundary, so the offsets are
+	// aligned once the file is mapped.
+	var padding [8]byte
+	bw.write(padding[:(8-bw.n%8)%8])
+	indexOffset := bw.n
+
+	// Use the smallest power of two number of groups that keeps the index at
+	// most 7/8 full.
+	groups := uint64(1)
+	for groups*groupSize*7/8 < uint64(len(slots)) {
+		groups *= 2
+	}
+	ctrl := make([]byte, groups*groupSize)
+	for i := range ctrl {
+		ctrl[i] = ctrlEmpty
+	}
+	offsets := make([]uint64, groups*groupSize)
+	for _, s := range slots {
+		for seq := makeProbeSeq(s.hash>>7, groups-1); ; seq = seq.next() {
+			g := ctrl[seq.offset*groupSize:][:groupSize]
+			if empties := findEmpty(binary.LittleEndian.Uint64(g)); empties != 0 {
+				i := bits.TrailingZeros64(empties) / 8
+				g[i] = byte(s.hash & 0x7F)
+				offsets[seq.offset*groupSize+uint64(i)] = s.offset
+				break
+			}
+		}
+	}
+
+	var group [groupBytes]byte
+	for gi := range groups {
+		copy(group[:], ctrl[gi*groupSize:][:groupSize])
+		for i := range uint64(groupSize) {
+			binary.LittleEndian.PutUint64(group[groupSize+8*i:], offsets[gi*groupSize+i])
+		}
+		bw.write(group[:])
+	}
+
+	var footer [footerSize]byte
+	binary.LittleEndian.PutUint64(footer[0:], seed)
+	binary.LittleEndian.PutUint64(footer[8:], groups)
+	binary.LittleEndian.PutUint64(footer[16:], uint64(len(slots)))
+	binary.LittleEndian.PutUint64(footer[24:], indexOffset)
+	bw.write(footer[:32])
+	binary.LittleEndian.PutUint32(footer[32:], bw.crc)
+	copy(footer[36:], magic)
+	bw.write(footer[32:])
+
+	if bw.err != nil {
+		return bw.err
+	}
+	return bw.w.Flush()
+}
+
+// checksumWriter writes to a bufio.Writer, keeping a count of the bytes
+// written and their checksum. It remembers the first error so Build can check
+// once after a series of writes.
+type checksumWriter struct {
+	w   *bufio.Writer
+	n   uint64
+	crc uint32
+	err error
+}
+
+func (c *checksumWriter) write(p []byte) {
+	if c.err != nil {
+		return
+	}
+	_, c.err = c.w.Write(p)
+	c.n += uint64(len(p))
+	c.crc = crc32.Update(c.crc, castagnoli, p)
+}
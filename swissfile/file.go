@@ -0,0 +1,202 @@
+//go:build unix
+
+package swissfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+	"os"
+	"syscall"
+)
+
+// File is a swissfile mapped into memory.
+type File struct {
+	mem   []byte
+	seed  uint64
+	mask  uint64
+	count int
+	// records is the part of the file holding the records, and index the
+	// part holding the groups.
+	records []byte
+	index   []byte
+}
+
+// Open maps the file at path into memory. Call Close when you're done with it.
+//
+// Open only checks the header, the footer and the index, so it doesn't read
+// the records and opening a large file is cheap: only the parts of the file
+// lookups touch need to be in memory. That's enough for every lookup to end,
+// and Get checks each record it reads is inside the file. But a corrupt
+// record can still give Get a wrong answer. Call Verify to check the whole
+// file.
+func Open(path string) (*File, error) {
+	osf, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer osf.Close()
+
+	fi, err := osf.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size < headerSize+footerSize {
+		return nil, fmt.Errorf("%w: %s is only %d bytes", ErrCorrupt, path, size)
+	}
+	if size != int64(int(size)) {
+		return nil, fmt.Errorf("%s is too large to map", path)
+	}
+
+	mem, err := syscall.Mmap(int(osf.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mapping %s: %w", path, err)
+	}
+	f, err := newFile(mem)
+	if err != nil {
+		syscall.Munmap(mem)
+		return nil, fmt.Errorf("%w: %s: %v", ErrCorrupt, path, err)
+	}
+	return f, nil
+}
+
+// newFile checks the file in mem. The errors it returns describe what's wrong
+// for Open to wrap in ErrCorrupt.
+func newFile(mem []byte) (*File, error) {
+	header, footer := mem[:headerSize], mem[len(mem)-footerSize:]
+	if string(header[:4]) != magic || string(footer[36:]) != magic {
+		return nil, fmt.Errorf("not a swissfile")
+	}
+	if header[4] != version {
+		return nil, fmt.Errorf("unsupported version %d", header[4])
+	}
+	seed := binary.LittleEndian.Uint64(footer[0:])
+	groups := binary.LittleEndian.Uint64(footer[8:])
+	count := binary.LittleEndian.Uint64(footer[16:])
+	indexOffset := binary.LittleEndian.Uint64(footer[24:])
+	indexEnd := uint64(len(mem) - footerSize)
+	if groups == 0 || groups&(groups-1) != 0 {
+		return nil, fmt.Errorf("%d groups isn't a power of two", groups)
+	}
+	if indexOffset < headerSize || indexOffset > indexEnd || (indexEnd-indexOffset)/groupBytes != groups || (indexEnd-indexOffset)%groupBytes != 0 {
+		return nil, fmt.Errorf("index of %d groups at %d doesn't fit", groups, indexOffset)
+	}
+
+	f := &File{
+		mem:     mem,
+		seed:    seed,
+		mask:    groups - 1,
+		records: mem[:indexOffset],
+		index:   mem[indexOffset:indexEnd],
+	}
+
+	// Get relies on there being an empty slot to end each probe sequence.
+	// We check the offsets are in the records, but leave checking there are
+	// whole records there to Verify, as that means reading every record.
+	var used, empty uint64
+	for gi := range groups {
+		g := f.index[gi*groupBytes:][:groupBytes]
+		for i, c := range g[:groupSize] {
+			switch {
+			case c == ctrlEmpty:
+				empty++
+			case c < 0x80:
+				used++
+				if offset := binary.LittleEndian.Uint64(g[groupSize+8*i:]); offset < headerSize || offset > uint64(len(f.records))-recordHeaderSize {
+					return nil, fmt.Errorf("group %d slot %d points outside the records", gi, i)
+				}
+			default:
+				return nil, fmt.Errorf("invalid control byte %#02x", c)
+			}
+		}
+	}
+	if used != count || empty == 0 {
+		return nil, fmt.Errorf("index has %d records and %d empty slots, expected %d records", used, empty, count)
+	}
+	f.count = int(count)
+	return f, nil
+}
+
+// Get returns the value for key. The value is part of the mapped file: it
+// must not be changed, and is only valid until Close is called. Get doesn't
+// allocate.
+func (f *File) Get(key []byte) (value []byte, ok bool) {
+	h := keyHash(f.seed, key)
+	h1Expanded := (h & 0x7F) * 0x0101_0101_0101_0101
+
+	for seq := makeProbeSeq(h>>7, f.mask); ; seq = seq.next() {
+		g := f.index[seq.offset*groupBytes:][:groupBytes]
+		ctrl := binary.LittleEndian.Uint64(g)
+		matches := findMatches(ctrl, h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			// A slot whose record isn't whole can't match. Verify reports it.
+			if k, v, ok := f.record(binary.LittleEndian.Uint64(g[groupSize+8*i:])); ok && bytes.Equal(k, key) {
+				return v, true
+			}
+			matches &= matches - 1
+		}
+		if findEmpty(ctrl) != 0 {
+			return nil, false
+		}
+	}
+}
+
+// record returns the key and value of the record at offset, and reports
+// whether there's a whole record there.
+func (f *File) record(offset uint64) (key, value []byte, ok bool) {
+	if offset < headerSize || offset > uint64(len(f.records))-recordHeaderSize {
+		return nil, nil, false
+	}
+	rec := f.records[offset:]
+	keyLen := uint64(binary.LittleEndian.Uint32(rec))
+	valueLen := uint64(binary.LittleEndian.Uint32(rec[4:]))
+	if keyLen+valueLen > uint64(len(rec))-recordHeaderSize {
+		return nil, nil, false
+	}
+	rec = rec[recordHeaderSize:]
+	return rec[:keyLen:keyLen], rec[keyLen : keyLen+valueLen : keyLen+valueLen], true
+}
+
+// Verify checks the whole file: that its checksum matches, and that every
+// slot in the index points at a whole record. It reads every byte of the file,
+// so it's much slower than Open. If the file is corrupt Verify returns an error
+// wrapping ErrCorrupt.
+func (f *File) Verify() error {
+	footer := f.mem[len(f.mem)-footerSize:]
+	if sum := crc32.Checksum(f.mem[:len(f.mem)-8], castagnoli); sum != binary.LittleEndian.Uint32(footer[32:]) {
+		return fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	for gi := range uint64(len(f.index) / groupBytes) {
+		g := f.index[gi*groupBytes:][:groupBytes]
+		for i, c := range g[:groupSize] {
+			if c == ctrlEmpty {
+				continue
+			}
+			if _, _, ok := f.record(binary.LittleEndian.Uint64(g[groupSize+8*i:])); !ok {
+				return fmt.Errorf("%w: group %d slot %d doesn't point at a whole record", ErrCorrupt, gi, i)
+			}
+		}
+	}
+	return nil
+}
+
+// Len returns the number of records in the file.
+func (f *File) Len() int { return f.count }
+
+// Close unmaps the file. The File and any values Get returned must not be
+// used after Close, except that calling Close again does nothing.
+func (f *File) Close() error {
+	if f.mem == nil {
+		return nil
+	}
+	mem := f.mem
+	*f = File{}
+	if err := syscall.Munmap(mem); err != nil {
+		return fmt.Errorf("unmapping file: %w", err)
+	}
+	return nil
+}
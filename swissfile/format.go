@@ -0,0 +1,118 @@
+// Package swissfile reads and writes immutable Swiss tables stored in files,
+// in the spirit of D. J. Bernstein's cdb.
+//
+// Build writes a file from a sequence of keys and values. Open maps the file
+// into memory and looks keys up in place: the index is laid out like the
+// groups of hashblog.SwissConcrete, so a lookup reads control bytes and
+// offsets straight from the mapped file without decoding anything or
+// allocating. As the file is mapped read-only, any number of processes can
+// share one copy of it in the page cache.
+//
+// A file is:
+//
+//	header   "swsf", a version byte and 3 zero bytes
+//	records  each a uint32 key length, a uint32 value length, the key and
+//	         the value
+//	padding  zero bytes up to a multiple of 8
+//	index    groups of 8 control bytes followed by the file offsets of the
+//	         records for those 8 slots, as uint64s
+//	footer   the hash seed, the number of groups, the number of records and
+//	         the offset of the index, each a uint64, then a CRC-32C of every
+//	         byte before it as a uint32, then "swsf"
+//
+// All integers are little-endian. The footer goes at the end because Build
+// doesn't know how big the index will be until it has seen every record, and
+// it writes to an io.Writer so it can't go back and fill in a header.
+//
+// Unlike the tables in hashblog, the hash can't be Go's runtime hash, as that
+// has a different seed in every process. We use our own, with a seed chosen
+// when the file is built and stored in the footer.
+package swissfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+const (
+	magic      = "swsf"
+	version    = 1
+	headerSize = 8
+	footerSize = 4*8 + 4 + 4
+
+	groupSize = 8
+	// groupBytes is the size of a group in the index: the control bytes and
+	// an offset for each slot.
+	groupBytes = groupSize + groupSize*8
+	// recordHeaderSize is the size of the lengths at the start of each
+	// record.
+	recordHeaderSize = 8
+
+	// ctrlEmpty marks an empty slot. The files are immutable, so unlike the
+	// tables in hashblog there are never any deleted slots.
+	ctrlEmpty = 0x80
+)
+
+// ErrCorrupt is returned by Open and Verify if the file isn't a valid
+// swissfile.
+var ErrCorrupt = errors.New("corrupt swissfile")
+
+// keyHash hashes key. It's in the style of wyhash: each 8 bytes of key is
+// mixed in with a 64x64 to 128 bit multiply, folding the two halves of the
+// result together.
+func keyHash(seed uint64, key []byte) uint64 {
+	const (
+		prime1 = 0xa076_1d64_78bd_642f
+		prime2 = 0xe703_7ed1_a0b4_28db
+		prime3 = 0x8ebc_6af0_9c88_c6e3
+	)
+	h := seed ^ prime1
+	length := uint64(len(key))
+	for len(key) > 8 {
+		h = mix(binary.LittleEndian.Uint64(key)^prime2, h^prime1)
+		key = key[8:]
+	}
+	// The last 0 to 8 bytes, padded with zeros. We mix in the length at the
+	// end, so keys that differ only in trailing zeros still hash
+	// differently.
+	var last [8]byte
+	copy(last[:], key)
+	h = mix(binary.LittleEndian.Uint64(last[:])^prime2, h^prime3)
+	return mix(h^length, seed^prime3)
+}
+
+func mix(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+// probeSeq is the same quadratic probe sequence the tables in hashblog use.
+type probeSeq struct {
+	mask   uint64
+	offset uint64
+	index  uint64
+}
+
+func makeProbeSeq(h, mask uint64) probeSeq {
+	return probeSeq{mask: mask, offset: h & mask}
+}
+
+func (s probeSeq) next() probeSeq {
+	s.index++
+	s.offset = (s.offset + s.index) & s.mask
+	return s
+}
+
+// findMatches returns a mask with the top bit set in each byte of ctrl that
+// equals the byte repeated in h1Expanded. It can give false positives. See
+// hashblog's groupCtrl.findMatches.
+func findMatches(ctrl, h1Expanded uint64) uint64 {
+	matchesAreZero := ctrl ^ h1Expanded
+	return ((matchesAreZero - 0x0101_0101_0101_0101) &^ matchesAreZero) & 0x8080_8080_8080_8080
+}
+
+// findEmpty returns a mask with the top bit set in each empty byte of ctrl.
+func findEmpty(ctrl uint64) uint64 {
+	return ctrl & 0x8080_8080_8080_8080
+}
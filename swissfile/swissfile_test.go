@@ -0,0 +1,265 @@
+//go:build unix
+
+package swissfile_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"iter"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog/swissfile"
+)
+
+// build writes a file from seq and returns its path.
+func build(t testing.TB, seq iter.Seq2[[]byte, []byte]) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.swsf")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := swissfile.Build(f, seq); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func open(t testing.TB, path string) *swissfile.File {
+	t.Helper()
+	f, err := swissfile.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// numbers produces n keys, with the values being the keys repeated.
+func numbers(n int) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		// Reuse the buffers to check Build doesn't keep them.
+		var key, value []byte
+		for i := range n {
+			key = strconv.AppendInt(key[:0], int64(i), 10)
+			value = append(append(value[:0], key...), key...)
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
+}
+
+func pairs(kv ...string) iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		for i := 0; i < len(kv); i += 2 {
+			if !yield([]byte(kv[i]), []byte(kv[i+1])) {
+				return
+			}
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 8, 100, 100_000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			f := open(t, build(t, numbers(n)))
+			if f.Len() != n {
+				t.Fatalf("Len() = %d, want %d", f.Len(), n)
+			}
+			if err := f.Verify(); err != nil {
+				t.Fatal(err)
+			}
+			for i := range n {
+				key := strconv.Itoa(i)
+				v, ok := f.Get([]byte(key))
+				if !ok || string(v) != key+key {
+					t.Fatalf("Get(%s) = %q, %t", key, v, ok)
+				}
+			}
+			for i := n; i < n+1000; i++ {
+				if v, ok := f.Get([]byte(strconv.Itoa(i))); ok {
+					t.Fatalf("Get(%d) = %q for a missing key", i, v)
+				}
+			}
+		})
+	}
+}
+
+func TestGetEdgeCases(t *testing.T) {
+	f := open(t, build(t, pairs(
+		"", "empty key",
+		"empty value", "",
+		"dup", "first",
+		"dup", "second",
+		"a\x00", "trailing zero",
+		"a", "no trailing zero",
+	)))
+	if f.Len() != 6 {
+		t.Fatalf("Len() = %d", f.Len())
+	}
+	for _, test := range []struct {
+		key, value string
+	}{
+		{"", "empty key"},
+		{"empty value", ""},
+		{"dup", "first"},
+		{"a\x00", "trailing zero"},
+		{"a", "no trailing zero"},
+	} {
+		if v, ok := f.Get([]byte(test.key)); !ok || string(v) != test.value {
+			t.Errorf("Get(%q) = %q, %t, want %q", test.key, v, ok, test.value)
+		}
+	}
+	if _, ok := f.Get(nil); !ok {
+		t.Errorf("nil key should match the empty key")
+	}
+}
+
+func TestGetAllocs(t *testing.T) {
+	f := open(t, build(t, numbers(1000)))
+	key, missing := []byte("500"), []byte("5000")
+	if n := testing.AllocsPerRun(100, func() {
+		f.Get(key)
+		f.Get(missing)
+	}); n != 0 {
+		t.Fatalf("Get allocates %v times", n)
+	}
+}
+
+func TestClose(t *testing.T) {
+	f, err := swissfile.Open(build(t, numbers(10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
+
+// firstSlot returns the position in b, a whole file, of the offset in the
+// first slot of the index that's in use.
+func firstSlot(b []byte) int {
+	index := int(binary.LittleEndian.Uint64(b[len(b)-16:]))
+	for i := index; ; i += 8 + 64 {
+		for j, c := range b[i : i+8] {
+			if c < 0x80 {
+				return i + 8 + 8*j
+			}
+		}
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	data, err := os.ReadFile(build(t, numbers(100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		corrupt func(b []byte) []byte
+		// verify is true if only Verify finds the problem, as Open doesn't
+		// read the records or check the checksum.
+		verify bool
+	}{
+		{"empty", func(b []byte) []byte { return nil }, false},
+		{"short", func(b []byte) []byte { return b[:20] }, false},
+		{"magic", func(b []byte) []byte { b[0] = 'x'; return b }, false},
+		{"footer magic", func(b []byte) []byte { b[len(b)-1] = 'x'; return b }, false},
+		{"version", func(b []byte) []byte { b[4] = 99; return b }, false},
+		{"control byte", func(b []byte) []byte { b[firstSlot(b)-8] = 0x90; return b }, false},
+		{"offset", func(b []byte) []byte {
+			binary.LittleEndian.PutUint64(b[firstSlot(b):], uint64(len(b)))
+			return b
+		}, false},
+		{"footer", func(b []byte) []byte { b[len(b)-30] ^= 1; return b }, false},
+		{"truncated", func(b []byte) []byte { return append(b[:len(b)-41], b[len(b)-40:]...) }, false},
+		{"trailing", func(b []byte) []byte { return append(b, 0) }, false},
+		{"record", func(b []byte) []byte { b[20] ^= 1; return b }, true},
+		{"checksum", func(b []byte) []byte { b[len(b)-6] ^= 1; return b }, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "corrupt.swsf")
+			if err := os.WriteFile(path, test.corrupt(bytes.Clone(data)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := swissfile.Open(path)
+			if test.verify {
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				err = f.Verify()
+			} else if err == nil {
+				f.Close()
+			}
+			if !errors.Is(err, swissfile.ErrCorrupt) {
+				t.Fatalf("expected ErrCorrupt, got %v", err)
+			}
+		})
+	}
+
+	if _, err := swissfile.Open(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+}
+
+// TestCorruptRecord checks Get doesn't trust a record Open hasn't checked.
+func TestCorruptRecord(t *testing.T) {
+	path := build(t, pairs("", "empty key"))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make the record's key run off the end of the records. Get must not
+	// take the missing key for an empty one.
+	binary.LittleEndian.PutUint32(data[8:], 0xFFFF_FFFF)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := open(t, path)
+	if v, ok := f.Get(nil); ok {
+		t.Fatalf("Get returned %q from a corrupt record", v)
+	}
+	if err := f.Verify(); !errors.Is(err, swissfile.ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt, got %v", err)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	const n = 1_000_000
+	f := open(b, build(b, numbers(n)))
+	keys := make([][]byte, 1024)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i * (n / len(keys))))
+	}
+
+	b.Run("hit", func(b *testing.B) {
+		for i := range b.N {
+			if _, ok := f.Get(keys[i%len(keys)]); !ok {
+				b.Fatal("missing")
+			}
+		}
+	})
+	b.Run("miss", func(b *testing.B) {
+		missing := []byte("not there")
+		for range b.N {
+			if _, ok := f.Get(missing); ok {
+				b.Fatal("found")
+			}
+		}
+	})
+}
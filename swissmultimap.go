@@ -0,0 +1,306 @@
+package hashblog
+
+import (
+	"iter"
+	"math/bits"
+)
+
+// multiInline is the number of values a SwissMultiMap keeps in each entry.
+const multiInline = 2
+
+// SwissMultiMap maps each key to a list of values. It uses the same layout and
+// probing as SwissTable.
+//
+// Most keys in the indexes we use this for have one or two values, so each
+// entry has room for two values of its own. Keys with more than that move the
+// rest of their values to a slice kept to one side. Keys with one or two values
+// cost no allocations at all.
+//
+// Values for a key are kept in the order they were added. Like the other
+// tables, SwissMultiMap can't grow, so it holds at most 32768 keys, but each key
+// can have any number of values.
+type SwissMultiMap[K, V comparable] struct {
+	groups [groupTableSize]multiGroup[K, V]
+	// spills holds the values that don't fit in the entries. free lists the
+	// indexes of spills that aren't in use.
+	spills [][]V
+	free   []int32
+	// len is the number of keys, and tombstones the number of deleted
+	// markers. See tombstones.
+	len        int
+	tombstones tombstones
+}
+
+type multiGroup[K, V comparable] struct {
+	ctrl    groupCtrl
+	entries [groupSize]multiEntry[K, V]
+}
+
+type multiEntry[K, V comparable] struct {
+	key K
+	// n is the number of values for the key. The first multiInline are in
+	// inline, and the rest in spills[spill].
+	n      int32
+	spill  int32
+	inline [multiInline]V
+}
+
+func NewSwissMultiMap[K, V comparable]() *SwissMultiMap[K, V] {
+	m := &SwissMultiMap[K, V]{}
+	for i := range m.groups {
+		m.groups[i].ctrl = groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}
+	}
+	return m
+}
+
+// Add adds value to the values for key. It doesn't check whether key already
+// has the value, so a key can have the same value more than once.
+func (m *SwissMultiMap[K, V]) Add(key K, value V) {
+	h := hash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101010101010101
+
+	var slotGroup *multiGroup[K, V]
+	var slot int
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; e.key == key {
+				m.append(e, value)
+				return
+			}
+			matches &= matches - 1
+		}
+		if slotGroup == nil {
+			if avail := g.ctrl.findEmptyOrDeleted(); avail != 0 {
+				slotGroup, slot = g, bits.TrailingZeros64(avail)/8
+			}
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			e := multiEntry[K, V]{key: key, n: 1}
+			e.inline[0] = value
+			if m.tombstones.tooMany(m.len) {
+				// Rehashing moves everything, including the slot we found.
+				m.rehash()
+				m.insert(e, h)
+				return
+			}
+			m.tombstones.reused(slotGroup.ctrl[slot])
+			slotGroup.entries[slot] = e
+			slotGroup.ctrl[slot] = h1
+			m.len++
+			return
+		}
+	}
+}
+
+// GetAll returns an iterator over the values for key, in the order they were
+// added. Don't change the values for key while iterating over them.
+func (m *SwissMultiMap[K, V]) GetAll(key K) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		e := m.find(key)
+		if e == nil {
+			return
+		}
+		for _, v := range e.inline[:min(e.n, multiInline)] {
+			if !yield(v) {
+				return
+			}
+		}
+		if e.n > multiInline {
+			for _, v := range m.spills[e.spill] {
+				if !yield(v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Count returns the number of values for key.
+func (m *SwissMultiMap[K, V]) Count(key K) int {
+	if e := m.find(key); e != nil {
+		return int(e.n)
+	}
+	return 0
+}
+
+// RemoveAll removes key and all its values. Adding keys rehashes the table if
+// the deleted markers this leaves pile up.
+func (m *SwissMultiMap[K, V]) RemoveAll(key K) {
+	g, i := m.findSlot(key)
+	if g == nil {
+		return
+	}
+	if e := &g.entries[i]; e.n > multiInline {
+		m.releaseSpill(e.spill)
+	}
+	g.entries[i] = multiEntry[K, V]{}
+	m.tombstones.deleted(g.ctrl.delete(i))
+	m.len--
+}
+
+// RemoveOne removes the first occurrence of value from the values for key, and
+// reports whether it found one. If that was the key's last value the key is
+// removed too.
+func (m *SwissMultiMap[K, V]) RemoveOne(key K, value V) bool {
+	e := m.find(key)
+	if e == nil {
+		return false
+	}
+	for i := range int(e.n) {
+		if m.value(e, i) != value {
+			continue
+		}
+		if e.n == 1 {
+			m.RemoveAll(key)
+			return true
+		}
+		// Shift the later values down to keep them in order.
+		for j := i; j < int(e.n)-1; j++ {
+			m.setValue(e, j, m.value(e, j+1))
+		}
+		m.truncate(e)
+		return true
+	}
+	return false
+}
+
+// rehash removes the deleted markers by taking every entry out and inserting
+// it again. See tombstones. The entries keep their spills.
+func (m *SwissMultiMap[K, V]) rehash() {
+	all := make([]multiEntry[K, V], 0, m.len)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl < 0x80 {
+				all = append(all, g.entries[i])
+			}
+		}
+		*g = multiGroup[K, V]{ctrl: groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}}
+	}
+	m.len, m.tombstones = 0, 0
+	for _, e := range all {
+		m.insert(e, hash(e.key))
+	}
+}
+
+// insert adds e, whose key has hash h and isn't already in the table, to the
+// first empty slot on its probe sequence.
+func (m *SwissMultiMap[K, V]) insert(e multiEntry[K, V], h hashValue) {
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			i := bits.TrailingZeros64(empties) / 8
+			g.entries[i] = e
+			g.ctrl[i] = byte(h & 0x7F)
+			m.len++
+			return
+		}
+	}
+}
+
+// append adds v to the values in e.
+func (m *SwissMultiMap[K, V]) append(e *multiEntry[K, V], v V) {
+	switch {
+	case e.n < multiInline:
+		e.inline[e.n] = v
+	case e.n == multiInline:
+		e.spill = m.newSpill()
+		m.spills[e.spill] = append(m.spills[e.spill], v)
+	default:
+		m.spills[e.spill] = append(m.spills[e.spill], v)
+	}
+	e.n++
+}
+
+// truncate removes the last value from e. e must have at least 2 values.
+func (m *SwissMultiMap[K, V]) truncate(e *multiEntry[K, V]) {
+	var zero V
+	e.n--
+	if e.n < multiInline {
+		e.inline[e.n] = zero
+		return
+	}
+	s := m.spills[e.spill]
+	s[len(s)-1] = zero
+	m.spills[e.spill] = s[:len(s)-1]
+	if e.n == multiInline {
+		m.releaseSpill(e.spill)
+	}
+}
+
+// value returns the i'th value in e.
+func (m *SwissMultiMap[K, V]) value(e *multiEntry[K, V], i int) V {
+	if i < multiInline {
+		return e.inline[i]
+	}
+	return m.spills[e.spill][i-multiInline]
+}
+
+func (m *SwissMultiMap[K, V]) setValue(e *multiEntry[K, V], i int, v V) {
+	if i < multiInline {
+		e.inline[i] = v
+		return
+	}
+	m.spills[e.spill][i-multiInline] = v
+}
+
+// newSpill returns the index of an empty spill slice, reusing one that has
+// been released if there is one.
+func (m *SwissMultiMap[K, V]) newSpill() int32 {
+	if n := len(m.free); n > 0 {
+		i := m.free[n-1]
+		m.free = m.free[:n-1]
+		return i
+	}
+	m.spills = append(m.spills, nil)
+	return int32(len(m.spills) - 1)
+}
+
+// releaseSpill makes a spill slice available for reuse. We keep its backing
+// array, but clear it so we don't hold on to anything the values point to.
+func (m *SwissMultiMap[K, V]) releaseSpill(i int32) {
+	s := m.spills[i]
+	clear(s)
+	m.spills[i] = s[:0]
+	m.free = append(m.free, i)
+}
+
+func (m *SwissMultiMap[K, V]) find(key K) *multiEntry[K, V] {
+	if m == nil {
+		return nil
+	}
+	if g, i := m.findSlot(key); g != nil {
+		return &g.entries[i]
+	}
+	return nil
+}
+
+// findSlot returns the group and slot holding key, or a nil group if key isn't
+// present.
+func (m *SwissMultiMap[K, V]) findSlot(key K) (*multiGroup[K, V], int) {
+	h := hash(key)
+	h1Expanded := uint64(h&0x7F) * 0x0101010101010101
+
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if g.entries[i].key == key {
+				return g, i
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return nil, 0
+		}
+	}
+}
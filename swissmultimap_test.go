@@ -0,0 +1,151 @@
+package hashblog_test
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+func TestSwissMultiMap(t *testing.T) {
+	m := hashblog.NewSwissMultiMap[string, int]()
+	ref := make(map[string][]int)
+	check := func(key string) {
+		t.Helper()
+		if got := slices.Collect(m.GetAll(key)); !slices.Equal(got, ref[key]) {
+			t.Fatalf("GetAll(%q) returned %v, expected %v", key, got, ref[key])
+		}
+		if got := m.Count(key); got != len(ref[key]) {
+			t.Fatalf("Count(%q) returned %d, expected %d", key, got, len(ref[key]))
+		}
+	}
+
+	// Keys have between 1 and 6 values, so some spill and some don't.
+	r := rand.New(rand.NewPCG(1, 2))
+	const numKeys = 2000
+	for range 100_000 {
+		k := r.IntN(numKeys)
+		key := strconv.Itoa(k)
+		switch op := r.IntN(10); {
+		case op < 5:
+			if len(ref[key]) < 1+k%6 {
+				v := r.IntN(4)
+				m.Add(key, v)
+				ref[key] = append(ref[key], v)
+			}
+		case op < 8:
+			v := r.IntN(4)
+			i := slices.Index(ref[key], v)
+			if got := m.RemoveOne(key, v); got != (i >= 0) {
+				t.Fatalf("RemoveOne(%q, %d) returned %t", key, v, got)
+			}
+			if i >= 0 {
+				ref[key] = slices.Delete(ref[key], i, i+1)
+				if len(ref[key]) == 0 {
+					delete(ref, key)
+				}
+			}
+		case op < 9:
+			m.RemoveAll(key)
+			delete(ref, key)
+		default:
+			check(key)
+		}
+	}
+	for k := range numKeys {
+		check(strconv.Itoa(k))
+	}
+}
+
+// TestSwissMultiMapChurn keeps a crowded table at the same number of keys while
+// removing old keys and adding new ones. See TestChurn. Each key has three
+// values, so rehashing has to keep the spilled ones.
+func TestSwissMultiMapChurn(t *testing.T) {
+	const (
+		live   = 28000
+		batch  = 2000
+		rounds = 100
+	)
+	m := hashblog.NewSwissMultiMap[int, int]()
+	add := func(k int) {
+		for v := range 3 {
+			m.Add(k, k+v)
+		}
+	}
+	for i := range live {
+		add(i)
+	}
+	for round := range rounds {
+		first := round * batch
+		for i := first; i < first+batch; i++ {
+			m.RemoveAll(i)
+		}
+		for i := first + live; i < first+live+batch; i++ {
+			add(i)
+		}
+		if m.Count(-1) != 0 {
+			t.Fatal("found a key we never added")
+		}
+		if free, empty := 32768-live, m.EmptySlots(); empty < free/2 {
+			t.Fatalf("round %d: only %d of %d free slots are empty", round, empty, free)
+		}
+	}
+	for i := rounds * batch; i < rounds*batch+live; i++ {
+		if got := slices.Collect(m.GetAll(i)); !slices.Equal(got, []int{i, i + 1, i + 2}) {
+			t.Fatalf("GetAll(%d) returned %v", i, got)
+		}
+	}
+}
+
+func TestSwissMultiMapIterateEarlyStop(t *testing.T) {
+	m := hashblog.NewSwissMultiMap[int, int]()
+	for i := range 5 {
+		m.Add(1, i)
+	}
+	for _, stop := range []int{1, 3} {
+		var got []int
+		for v := range m.GetAll(1) {
+			got = append(got, v)
+			if len(got) == stop {
+				break
+			}
+		}
+		if len(got) != stop {
+			t.Fatalf("expected %d values before stopping, got %v", stop, got)
+		}
+	}
+	for range m.GetAll(2) {
+		t.Fatal("missing key has values")
+	}
+}
+
+func TestSwissMultiMapAllocs(t *testing.T) {
+	m := hashblog.NewSwissMultiMap[int, int]()
+	var k int
+	allocs := testing.AllocsPerRun(1000, func() {
+		m.Add(k, 1)
+		m.Add(k, 2)
+		m.RemoveOne(k, 1)
+		k++
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations for keys with up to 2 values, got %f", allocs)
+	}
+
+	// Once a spill slice has been released, it's reused.
+	m.Add(-1, 1)
+	m.Add(-1, 2)
+	m.Add(-1, 3)
+	m.RemoveAll(-1)
+	allocs = testing.AllocsPerRun(1000, func() {
+		m.Add(-2, 1)
+		m.Add(-2, 2)
+		m.Add(-2, 3)
+		m.RemoveAll(-2)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected spill slices to be reused, got %f allocations", allocs)
+	}
+}
@@ -0,0 +1,270 @@
+package hashblog
+
+import (
+	"iter"
+	"math/bits"
+)
+
+// SwissSet is a set of keys, laid out like SwissTable but without values.
+//
+// SwissTable[K, struct{}] works as a set, but each entry still has room for a
+// value: a zero-sized field at the end of a struct takes up space so a pointer
+// to it doesn't point past the struct. For string keys that makes each entry 24
+// bytes rather than 16. SwissSet's groups hold only the keys.
+//
+// Union, Intersect, Difference and IsSubset walk one set group by group and
+// look each key up in the other. Every SwissSet has the same size and uses the
+// same hash seed, and most keys sit in the group their probe sequence starts
+// at, so those lookups move through the other set in order too rather than
+// jumping about. They are still ordinary hashed lookups, one per key: where a
+// key ends up depends on what was added and removed before it, so we can't
+// just merge the two sets' groups.
+//
+// A nil *SwissSet is an empty set, except that you can't Add to it.
+//
+// Like the other tables, SwissSet can't grow. Adding a key to a set that holds
+// SwissSetMaxLen keys panics, and so does a Union that would hold more.
+type SwissSet[K comparable] struct {
+	groups [groupTableSize]setGroup[K]
+	len    int
+	// tombstones is the number of deleted markers. See tombstones.
+	tombstones tombstones
+}
+
+type setGroup[K comparable] struct {
+	ctrl groupCtrl
+	keys [groupSize]K
+}
+
+// SwissSetMaxLen is the most keys a SwissSet can hold. As with Dictionary, we
+// keep an eighth of the set free so probe sequences stay short.
+const SwissSetMaxLen = simpleTableSize * 7 / 8
+
+func NewSwissSet[K comparable]() *SwissSet[K] {
+	s := &SwissSet[K]{}
+	for i := range s.groups {
+		s.groups[i].ctrl = groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}
+	}
+	return s
+}
+
+// Add adds key to the set.
+func (s *SwissSet[K]) Add(key K) {
+	h := hash(key)
+	if s.has(key, h) {
+		return
+	}
+	s.insert(key, h)
+}
+
+// Has reports whether key is in the set.
+func (s *SwissSet[K]) Has(key K) bool {
+	if s == nil {
+		return false
+	}
+	return s.has(key, hash(key))
+}
+
+// Remove removes key from the set. See SwissTable.Delete for how this works.
+// Adding keys rehashes the set if the deleted markers pile up.
+func (s *SwissSet[K]) Remove(key K) {
+	if s == nil {
+		return
+	}
+	h := hash(key)
+	h1Expanded := uint64(h&0x7F) * 0x0101010101010101
+
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &s.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if g.keys[i] == key {
+				var zero K
+				g.keys[i] = zero
+				s.tombstones.deleted(g.ctrl.delete(i))
+				s.len--
+				return
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return
+		}
+	}
+}
+
+// Len returns the number of keys in the set.
+func (s *SwissSet[K]) Len() int {
+	if s == nil {
+		return 0
+	}
+	return s.len
+}
+
+// All returns an iterator over the keys in the set, in no particular order.
+// Don't change the set while iterating over it.
+func (s *SwissSet[K]) All() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		if s == nil {
+			return
+		}
+		for gi := range s.groups {
+			g := &s.groups[gi]
+			for i, ctrl := range g.ctrl {
+				if ctrl < 0x80 && !yield(g.keys[i]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Union returns a new set holding the keys that are in either s or other.
+//
+// As both sets have the same layout we start by copying the groups of one of
+// them wholesale, then add the keys from the other that aren't already there.
+// Union panics if that comes to more than SwissSetMaxLen keys.
+func (s *SwissSet[K]) Union(other *SwissSet[K]) *SwissSet[K] {
+	if s == nil {
+		s, other = other, s
+		if s == nil {
+			return NewSwissSet[K]()
+		}
+	}
+	out := &SwissSet[K]{}
+	*out = *s
+	other.eachKey(func(key K, h hashValue) {
+		if !out.has(key, h) {
+			out.insert(key, h)
+		}
+	})
+	return out
+}
+
+// Intersect returns a new set holding the keys that are in both s and other.
+func (s *SwissSet[K]) Intersect(other *SwissSet[K]) *SwissSet[K] {
+	out := NewSwissSet[K]()
+	if s == nil || other == nil {
+		return out
+	}
+	// Walk whichever set is smaller.
+	small, large := s, other
+	if large.len < small.len {
+		small, large = large, small
+	}
+	small.eachKey(func(key K, h hashValue) {
+		if large.has(key, h) {
+			out.insert(key, h)
+		}
+	})
+	return out
+}
+
+// Difference returns a new set holding the keys in s that aren't in other.
+func (s *SwissSet[K]) Difference(other *SwissSet[K]) *SwissSet[K] {
+	out := NewSwissSet[K]()
+	s.eachKey(func(key K, h hashValue) {
+		if other == nil || !other.has(key, h) {
+			out.insert(key, h)
+		}
+	})
+	return out
+}
+
+// IsSubset reports whether every key in s is also in other.
+func (s *SwissSet[K]) IsSubset(other *SwissSet[K]) bool {
+	if s.Len() > other.Len() {
+		return false
+	}
+	if s == nil || other == nil {
+		// Only an empty set gets this far.
+		return true
+	}
+	for gi := range s.groups {
+		g := &s.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl < 0x80 && !other.has(g.keys[i], hash(g.keys[i])) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// eachKey calls fn for each key in the set, with its hash, group by group.
+func (s *SwissSet[K]) eachKey(fn func(key K, h hashValue)) {
+	if s == nil {
+		return
+	}
+	for gi := range s.groups {
+		g := &s.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl < 0x80 {
+				fn(g.keys[i], hash(g.keys[i]))
+			}
+		}
+	}
+}
+
+// has reports whether key, which has hash h, is in the set.
+func (s *SwissSet[K]) has(key K, h hashValue) bool {
+	h1Expanded := uint64(h&0x7F) * 0x0101010101010101
+
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &s.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if g.keys[i] == key {
+				return true
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return false
+		}
+	}
+}
+
+// insert adds key, which has hash h, to the set. The key must not already be
+// in the set. It goes in the first empty or deleted slot on its probe
+// sequence, unless there are so many deleted slots that we rehash first.
+func (s *SwissSet[K]) insert(key K, h hashValue) {
+	if s.len == SwissSetMaxLen {
+		panic("hashblog: SwissSet is full")
+	}
+	if s.tombstones.tooMany(s.len) {
+		s.rehash()
+	}
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &s.groups[seq.offset]
+		if avail := g.ctrl.findEmptyOrDeleted(); avail != 0 {
+			i := bits.TrailingZeros64(avail) / 8
+			s.tombstones.reused(g.ctrl[i])
+			g.keys[i] = key
+			g.ctrl[i] = byte(h & 0x7F)
+			s.len++
+			return
+		}
+	}
+}
+
+// rehash removes the deleted markers by taking every key out and inserting it
+// again. See tombstones.
+func (s *SwissSet[K]) rehash() {
+	keys := make([]K, 0, s.len)
+	for gi := range s.groups {
+		g := &s.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl < 0x80 {
+				keys = append(keys, g.keys[i])
+			}
+		}
+		*g = setGroup[K]{ctrl: groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}}
+	}
+	s.len, s.tombstones = 0, 0
+	for _, key := range keys {
+		s.insert(key, hash(key))
+	}
+}
@@ -0,0 +1,283 @@
+package hashblog_test
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+func TestSwissSet(t *testing.T) {
+	s := hashblog.NewSwissSet[string]()
+	ref := make(map[string]bool)
+	check := func() {
+		t.Helper()
+		if s.Len() != len(ref) {
+			t.Fatalf("expected %d keys, got %d", len(ref), s.Len())
+		}
+		got := slices.Sorted(s.All())
+		want := slices.Sorted(maps.Keys(ref))
+		if !slices.Equal(got, want) {
+			t.Fatalf("All returned %d keys that don't match the %d expected", len(got), len(want))
+		}
+		for i := range 12000 {
+			key := strconv.Itoa(i)
+			if s.Has(key) != ref[key] {
+				t.Fatalf("Has(%q) returned %t", key, !ref[key])
+			}
+		}
+	}
+
+	for i := range 10000 {
+		key := strconv.Itoa(i)
+		s.Add(key)
+		ref[key] = true
+	}
+	check()
+
+	// Adding keys again changes nothing.
+	for i := range 100 {
+		s.Add(strconv.Itoa(i))
+	}
+	check()
+
+	for i := 0; i < 10000; i += 3 {
+		key := strconv.Itoa(i)
+		s.Remove(key)
+		delete(ref, key)
+	}
+	// Removing keys that aren't there changes nothing.
+	s.Remove("missing")
+	check()
+
+	// Deleted slots are reused.
+	for i := 0; i < 12000; i += 2 {
+		key := strconv.Itoa(i)
+		s.Add(key)
+		ref[key] = true
+	}
+	check()
+
+	// Stopping iteration early works.
+	var n int
+	for range s.All() {
+		if n++; n == 10 {
+			break
+		}
+	}
+	if n != 10 {
+		t.Fatalf("expected iteration to stop after 10 keys, got %d", n)
+	}
+}
+
+// TestSwissSetChurn keeps a crowded set at the same size while removing old
+// keys and adding new ones. See TestChurn.
+func TestSwissSetChurn(t *testing.T) {
+	const (
+		live   = 28000
+		batch  = 2000
+		rounds = 100
+	)
+	s := hashblog.NewSwissSet[int]()
+	for i := range live {
+		s.Add(i)
+	}
+	for round := range rounds {
+		first := round * batch
+		for i := first; i < first+batch; i++ {
+			s.Remove(i)
+		}
+		for i := first + live; i < first+live+batch; i++ {
+			s.Add(i)
+		}
+		if s.Has(-1) {
+			t.Fatal("found a key we never added")
+		}
+		if s.Len() != live {
+			t.Fatalf("round %d: expected %d keys, got %d", round, live, s.Len())
+		}
+		if free, empty := 32768-live, s.EmptySlots(); empty < free/2 {
+			t.Fatalf("round %d: only %d of %d free slots are empty", round, empty, free)
+		}
+	}
+	for i := rounds * batch; i < rounds*batch+live; i++ {
+		if !s.Has(i) {
+			t.Fatalf("key %d is missing", i)
+		}
+	}
+}
+
+func TestSwissSetNil(t *testing.T) {
+	var s *hashblog.SwissSet[int]
+	if s.Has(1) || s.Len() != 0 {
+		t.Fatal("nil set isn't empty")
+	}
+	for range s.All() {
+		t.Fatal("nil set has keys")
+	}
+	s.Remove(1)
+
+	a := setOf(0, 100, 1)
+	for _, test := range []struct {
+		name string
+		got  *hashblog.SwissSet[int]
+		want int
+	}{
+		{"Union", s.Union(a), 100},
+		{"UnionReversed", a.Union(s), 100},
+		{"UnionNil", s.Union(s), 0},
+		{"Intersect", s.Intersect(a), 0},
+		{"IntersectReversed", a.Intersect(s), 0},
+		{"Difference", s.Difference(a), 0},
+		{"DifferenceReversed", a.Difference(s), 100},
+	} {
+		if test.got == nil || test.got.Len() != test.want {
+			t.Errorf("%s: expected %d keys, got %d", test.name, test.want, test.got.Len())
+		}
+	}
+
+	// The result of a Union is a new set, even when one side is nil.
+	u := a.Union(s)
+	u.Add(-1)
+	if a.Has(-1) {
+		t.Fatal("adding to a union changed its input")
+	}
+}
+
+func TestSwissSetFull(t *testing.T) {
+	a := setOf(0, hashblog.SwissSetMaxLen, 1)
+	if a.Len() != hashblog.SwissSetMaxLen {
+		t.Fatalf("expected %d keys, got %d", hashblog.SwissSetMaxLen, a.Len())
+	}
+	// Adding a key that's already there is fine.
+	a.Add(0)
+
+	expectPanic := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if r := recover(); r != "hashblog: SwissSet is full" {
+				t.Errorf("%s: expected to panic because the set is full, got %v", name, r)
+			}
+		}()
+		fn()
+	}
+	expectPanic("Add", func() { a.Add(-1) })
+	expectPanic("Union", func() { setOf(0, 1000, 1).Union(setOf(1000, 30000, 1)) })
+}
+
+// setOf returns a set holding the numbers from start to end that are
+// multiples of step.
+func setOf(start, end, step int) *hashblog.SwissSet[int] {
+	s := hashblog.NewSwissSet[int]()
+	for i := start; i < end; i++ {
+		if i%step == 0 {
+			s.Add(i)
+		}
+	}
+	return s
+}
+
+func TestSwissSetAlgebra(t *testing.T) {
+	// a holds the even numbers below 10000, and b the multiples of 3 from
+	// 5000 to 15000.
+	a := setOf(0, 10000, 2)
+	b := setOf(5000, 15000, 3)
+
+	for _, test := range []struct {
+		name string
+		got  *hashblog.SwissSet[int]
+		want func(i int) bool
+	}{
+		{"Union", a.Union(b), func(i int) bool { return a.Has(i) || b.Has(i) }},
+		{"Intersect", a.Intersect(b), func(i int) bool { return a.Has(i) && b.Has(i) }},
+		{"IntersectReversed", b.Intersect(a), func(i int) bool { return a.Has(i) && b.Has(i) }},
+		{"Difference", a.Difference(b), func(i int) bool { return a.Has(i) && !b.Has(i) }},
+		{"DifferenceReversed", b.Difference(a), func(i int) bool { return b.Has(i) && !a.Has(i) }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var want int
+			for i := range 16000 {
+				if w := test.want(i); w != test.got.Has(i) {
+					t.Fatalf("Has(%d) returned %t", i, !w)
+				} else if w {
+					want++
+				}
+			}
+			if test.got.Len() != want {
+				t.Fatalf("expected %d keys, got %d", want, test.got.Len())
+			}
+		})
+	}
+
+	// The sets we started with are unchanged.
+	if a.Len() != 5000 || b.Len() != 3333 {
+		t.Fatalf("inputs changed: %d and %d keys", a.Len(), b.Len())
+	}
+
+	// Adding to a union doesn't change the set it was copied from.
+	u := a.Union(b)
+	u.Add(-1)
+	if a.Has(-1) {
+		t.Fatal("adding to a union changed its input")
+	}
+}
+
+func TestSwissSetIsSubset(t *testing.T) {
+	evens := setOf(0, 10000, 2)
+	fours := setOf(0, 10000, 4)
+	moreFours := setOf(0, 10004, 4)
+	empty := hashblog.NewSwissSet[int]()
+
+	for _, test := range []struct {
+		name string
+		s, o *hashblog.SwissSet[int]
+		want bool
+	}{
+		{"fours in evens", fours, evens, true},
+		{"evens in fours", evens, fours, false},
+		{"too many fours", moreFours, evens, false},
+		{"itself", evens, evens, true},
+		{"empty", empty, evens, true},
+		{"in empty", evens, empty, false},
+		{"nil", nil, evens, true},
+		{"in nil", evens, nil, false},
+		{"empty in nil", empty, nil, true},
+		{"nil in nil", nil, nil, true},
+	} {
+		if got := test.s.IsSubset(test.o); got != test.want {
+			t.Errorf("%s: expected %t, got %t", test.name, test.want, got)
+		}
+	}
+}
+
+func BenchmarkSwissSetIntersect(b *testing.B) {
+	x := setOf(0, 20000, 1)
+	y := setOf(10000, 30000, 1)
+
+	b.Run("i=SwissSet", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			x.Intersect(y)
+		}
+	})
+	b.Run("i=map", func(b *testing.B) {
+		mx, my := make(map[int]struct{}), make(map[int]struct{})
+		for i := range x.All() {
+			mx[i] = struct{}{}
+		}
+		for i := range y.All() {
+			my[i] = struct{}{}
+		}
+		b.ReportAllocs()
+		for b.Loop() {
+			out := make(map[int]struct{})
+			for k := range mx {
+				if _, ok := my[k]; ok {
+					out[k] = struct{}{}
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,226 @@
+package hashblog
+
+import (
+	"math/bits"
+	"time"
+)
+
+// ttlSweepGroups is the number of groups Set sweeps for expired entries each
+// time it is called. The sweep goes right round the table every 2048 calls to
+// Set.
+const ttlSweepGroups = 2
+
+// SwissTTL is a SwissTable whose entries can expire.
+//
+// Expired entries are removed in two ways. Get removes an expired entry when it
+// finds one. And each call to Set sweeps a couple of groups, removing any
+// expired entries in them. The sweep works round the table a little at a time,
+// so no single call has to do much work, but expired entries that nobody looks
+// up don't stay in the table for long. The table can't grow, so it's important
+// they don't build up. Call Sweep to do more sweeping, say from a timer if Set
+// isn't called often.
+type SwissTTL[K comparable, V any] struct {
+	groups [groupTableSize]ttlGroup[K, V]
+	now    func() time.Time
+	len    int
+	// tombstones is the number of deleted markers. See tombstones.
+	tombstones tombstones
+	// sweep is the next group to sweep.
+	sweep int
+}
+
+type ttlGroup[K comparable, V any] struct {
+	ctrl    groupCtrl
+	entries [groupSize]ttlEntry[K, V]
+}
+
+type ttlEntry[K comparable, V any] struct {
+	key   K
+	value V
+	// expires is when the entry expires, in nanoseconds since the Unix epoch.
+	// Zero means it never expires.
+	expires int64
+}
+
+// NewSwissTTL returns an empty table. now tells the table the time. If it is
+// nil the table uses time.Now. Tests can pass a fake clock.
+func NewSwissTTL[K comparable, V any](now func() time.Time) *SwissTTL[K, V] {
+	if now == nil {
+		now = time.Now
+	}
+	m := &SwissTTL[K, V]{now: now}
+	for i := range m.groups {
+		m.groups[i].ctrl = groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}
+	}
+	return m
+}
+
+// Set sets the value for key. The entry never expires.
+func (m *SwissTTL[K, V]) Set(key K, value V) {
+	m.set(key, value, 0)
+}
+
+// SetWithTTL sets the value for key. The entry expires after ttl.
+func (m *SwissTTL[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	m.set(key, value, m.now().Add(ttl).UnixNano())
+}
+
+func (m *SwissTTL[K, V]) set(key K, value V, expires int64) {
+	m.Sweep(ttlSweepGroups)
+
+	h := hash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101010101010101
+
+	var slotGroup *ttlGroup[K, V]
+	var slot int
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; e.key == key {
+				// It doesn't matter if the old entry has expired. We're
+				// replacing it anyway.
+				e.value, e.expires = value, expires
+				return
+			}
+			matches &= matches - 1
+		}
+		if slotGroup == nil {
+			if avail := g.ctrl.findEmptyOrDeleted(); avail != 0 {
+				slotGroup, slot = g, bits.TrailingZeros64(avail)/8
+			}
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			e := ttlEntry[K, V]{key: key, value: value, expires: expires}
+			if m.tombstones.tooMany(m.len) {
+				// Rehashing moves everything, including the slot we found.
+				m.rehash()
+				m.insert(e, h)
+				return
+			}
+			m.tombstones.reused(slotGroup.ctrl[slot])
+			slotGroup.entries[slot] = e
+			slotGroup.ctrl[slot] = h1
+			m.len++
+			return
+		}
+	}
+}
+
+// Get returns the value for key, if it is present and hasn't expired. If the
+// entry has expired Get removes it.
+func (m *SwissTTL[K, V]) Get(key K) (v V, ok bool) {
+	if m == nil {
+		return v, false
+	}
+	g, i := m.find(key)
+	if g == nil {
+		return v, false
+	}
+	e := &g.entries[i]
+	if e.expires != 0 && e.expires <= m.now().UnixNano() {
+		m.remove(g, i)
+		return v, false
+	}
+	return e.value, true
+}
+
+// Delete removes key from the table.
+func (m *SwissTTL[K, V]) Delete(key K) {
+	if g, i := m.find(key); g != nil {
+		m.remove(g, i)
+	}
+}
+
+// Len returns the number of entries in the table. This includes entries that
+// have expired but haven't been removed yet.
+func (m *SwissTTL[K, V]) Len() int { return m.len }
+
+// Sweep looks through the next n groups for expired entries and removes them.
+// It returns the number of entries it removed.
+func (m *SwissTTL[K, V]) Sweep(n int) (removed int) {
+	now := m.now().UnixNano()
+	for range min(n, groupTableSize) {
+		g := &m.groups[m.sweep]
+		m.sweep = (m.sweep + 1) & (groupTableSize - 1)
+		for i, ctrl := range g.ctrl {
+			if ctrl >= 0x80 {
+				continue
+			}
+			if e := &g.entries[i]; e.expires != 0 && e.expires <= now {
+				m.remove(g, i)
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+// remove removes the entry in slot i of g.
+func (m *SwissTTL[K, V]) remove(g *ttlGroup[K, V], i int) {
+	g.entries[i] = ttlEntry[K, V]{}
+	m.tombstones.deleted(g.ctrl.delete(i))
+	m.len--
+}
+
+// rehash removes the deleted markers by taking every entry out and inserting
+// it again. See tombstones.
+func (m *SwissTTL[K, V]) rehash() {
+	all := make([]ttlEntry[K, V], 0, m.len)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i, ctrl := range g.ctrl {
+			if ctrl < 0x80 {
+				all = append(all, g.entries[i])
+			}
+		}
+		*g = ttlGroup[K, V]{ctrl: groupCtrl{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}}
+	}
+	m.len, m.tombstones = 0, 0
+	for _, e := range all {
+		m.insert(e, hash(e.key))
+	}
+}
+
+// insert adds e, whose key has hash h and isn't already in the table, to the
+// first empty slot on its probe sequence.
+func (m *SwissTTL[K, V]) insert(e ttlEntry[K, V], h hashValue) {
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			i := bits.TrailingZeros64(empties) / 8
+			g.entries[i] = e
+			g.ctrl[i] = byte(h & 0x7F)
+			m.len++
+			return
+		}
+	}
+}
+
+// find returns the group and slot holding key, or a nil group if key isn't
+// present.
+func (m *SwissTTL[K, V]) find(key K) (*ttlGroup[K, V], int) {
+	h := hash(key)
+	h1Expanded := uint64(h&0x7F) * 0x0101010101010101
+
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if g.entries[i].key == key {
+				return g, i
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return nil, 0
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package hashblog_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/philpearl/hashblog"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+func newFakeClock() *fakeClock               { return &fakeClock{now: time.Unix(1_000_000, 0)} }
+func (c *fakeClock) table() *hashblog.SwissTTL[string, int] {
+	return hashblog.NewSwissTTL[string, int](c.Now)
+}
+
+func TestSwissTTL(t *testing.T) {
+	clock := newFakeClock()
+	m := clock.table()
+
+	m.SetWithTTL("a", 1, time.Second)
+	m.SetWithTTL("b", 2, 2*time.Second)
+	m.Set("c", 3)
+
+	check := func(key string, want int, wantOK bool) {
+		t.Helper()
+		if v, ok := m.Get(key); ok != wantOK || v != want {
+			t.Fatalf("Get(%q) = %d, %t, expected %d, %t", key, v, ok, want, wantOK)
+		}
+	}
+
+	check("a", 1, true)
+	check("b", 2, true)
+	check("c", 3, true)
+
+	// Entries expire at exactly their TTL.
+	clock.Advance(time.Second)
+	check("a", 0, false)
+	check("b", 2, true)
+	if m.Len() != 2 {
+		t.Fatalf("expected Get to remove the expired entry, have %d entries", m.Len())
+	}
+
+	// Setting an entry again resets its TTL, and Set removes it.
+	m.SetWithTTL("b", 4, time.Hour)
+	clock.Advance(time.Minute)
+	check("b", 4, true)
+	m.Set("b", 5)
+	clock.Advance(24 * time.Hour)
+	check("b", 5, true)
+	check("c", 3, true)
+
+	m.Delete("c")
+	check("c", 0, false)
+	if m.Len() != 1 {
+		t.Fatalf("expected 1 entry, have %d", m.Len())
+	}
+}
+
+func TestSwissTTLSweep(t *testing.T) {
+	clock := newFakeClock()
+	m := clock.table()
+
+	for i := range 10_000 {
+		if i%2 == 0 {
+			m.SetWithTTL(strconv.Itoa(i), i, time.Minute)
+		} else {
+			m.Set(strconv.Itoa(i), i)
+		}
+	}
+	if m.Len() != 10_000 {
+		t.Fatalf("expected 10000 entries, have %d", m.Len())
+	}
+
+	// Nothing has expired yet, so sweeping doesn't remove anything.
+	if n := m.Sweep(4096); n != 0 {
+		t.Fatalf("sweep removed %d entries before any expired", n)
+	}
+
+	clock.Advance(time.Minute)
+	// A few groups at a time.
+	var removed int
+	for range 4096 / 16 {
+		n := m.Sweep(16)
+		if n > 16*8 {
+			t.Fatalf("sweeping 16 groups removed %d entries", n)
+		}
+		removed += n
+	}
+	if removed != 5000 || m.Len() != 5000 {
+		t.Fatalf("expected to remove 5000 entries leaving 5000, removed %d leaving %d", removed, m.Len())
+	}
+	for i := range 10_000 {
+		v, ok := m.Get(strconv.Itoa(i))
+		if want := i%2 == 1; ok != want || (ok && v != i) {
+			t.Fatalf("Get(%d) = %d, %t", i, v, ok)
+		}
+	}
+}
+
+// TestSwissTTLChurn sets far more keys than the table can hold, as a session
+// store would, and never looks them up. Only the sweep in Set removes the
+// expired entries. Without it the table would fill and Set would never return.
+func TestSwissTTLChurn(t *testing.T) {
+	clock := newFakeClock()
+	m := clock.table()
+
+	for i := range 1_000_000 {
+		m.SetWithTTL(strconv.Itoa(i), i, time.Second)
+		clock.Advance(time.Millisecond)
+		// The table holds the last second's keys plus those that have expired
+		// since the sweep last passed their group. The sweep goes round the
+		// table every 2048 calls to Set.
+		if m.Len() > 1000+2048 {
+			t.Fatalf("after %d sets table has %d entries", i+1, m.Len())
+		}
+	}
+
+	// The keys from the last half second are all still there.
+	for i := 1_000_000 - 500; i < 1_000_000; i++ {
+		if v, ok := m.Get(strconv.Itoa(i)); !ok || v != i {
+			t.Fatalf("Get(%d) = %d, %t", i, v, ok)
+		}
+	}
+	if _, ok := m.Get("0"); ok {
+		t.Fatalf("key 0 should have expired")
+	}
+}
+
+func TestSwissTTLRealClock(t *testing.T) {
+	m := hashblog.NewSwissTTL[int, int](nil)
+	m.SetWithTTL(1, 1, time.Hour)
+	m.SetWithTTL(2, 2, -time.Second)
+	if _, ok := m.Get(1); !ok {
+		t.Fatalf("expected 1 to be present")
+	}
+	if _, ok := m.Get(2); ok {
+		t.Fatalf("expected 2 to have expired")
+	}
+}
+
+func BenchmarkSwissTTLGet(b *testing.B) {
+	keys := make([]string, 1<<14)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	for _, ttl := range []bool{false, true} {
+		b.Run("ttl="+strconv.FormatBool(ttl), func(b *testing.B) {
+			m := hashblog.NewSwissTTL[string, int](nil)
+			for i, k := range keys {
+				if ttl {
+					m.SetWithTTL(k, i, time.Hour)
+				} else {
+					m.Set(k, i)
+				}
+			}
+			b.ResetTimer()
+			for i := range b.N {
+				if _, ok := m.Get(keys[i&(len(keys)-1)]); !ok {
+					b.Fatal("key not found")
+				}
+			}
+		})
+	}
+}
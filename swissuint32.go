@@ -0,0 +1,83 @@
+package hashblog
+
+import "math/bits"
+
+// SwissUint32 is SwissUint64 for uint32 keys.
+//
+// If we kept keys and values together as entries, each uint32 key would be
+// padded out to 8 bytes to align the int that follows it. So instead each
+// group keeps its keys and values in separate arrays, which makes a group 104
+// bytes rather than 136.
+type SwissUint32 struct {
+	groups [groupTableSize]uint32Group
+}
+
+func NewSwissUint32() *SwissUint32 {
+	m := &SwissUint32{}
+	for i := range m.groups {
+		m.groups[i].ctrl = concreteCtrl(0x8080_8080_8080_8080)
+	}
+	return m
+}
+
+func (m *SwissUint32) Set(key uint32, value int) {
+	h := intHash(uint64(key))
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101_0101_0101_0101
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if g.keys[i] == key {
+				g.values[i] = value
+				return
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			i := bits.TrailingZeros64(empties) / 8
+			g.keys[i] = key
+			g.values[i] = value
+			g.ctrl.set(i, h1)
+			return
+		}
+	}
+}
+
+func (m *SwissUint32) Get(key uint32) (v int, ok bool) {
+	if m == nil {
+		return v, false
+	}
+	h := intHash(uint64(key))
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101_0101_0101_0101
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if g.keys[i] == key {
+				return g.values[i], true
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return v, false
+		}
+	}
+}
+
+type uint32Group struct {
+	ctrl   concreteCtrl
+	keys   [groupSize]uint32
+	values [groupSize]int
+}
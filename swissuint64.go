@@ -0,0 +1,87 @@
+package hashblog
+
+import "math/bits"
+
+// SwissUint64 is SwissConcrete for uint64 keys.
+//
+// SwissConcrete showed that avoiding generics and going straight to the
+// runtime's memhash saves a few nanoseconds. For integer keys we can do better
+// still: intHash is a few multiplies and shifts, and comparing keys is a single
+// instruction. The entries also hold the key directly, with no string header
+// to follow.
+type SwissUint64 struct {
+	groups [groupTableSize]uint64Group
+}
+
+func NewSwissUint64() *SwissUint64 {
+	m := &SwissUint64{}
+	for i := range m.groups {
+		m.groups[i].ctrl = concreteCtrl(0x8080_8080_8080_8080)
+	}
+	return m
+}
+
+func (m *SwissUint64) Set(key uint64, value int) {
+	h := intHash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101_0101_0101_0101
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; e.key == key {
+				e.value = value
+				return
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			i := bits.TrailingZeros64(empties) / 8
+			g.entries[i] = uint64Entry{key: key, value: value}
+			g.ctrl.set(i, h1)
+			return
+		}
+	}
+}
+
+func (m *SwissUint64) Get(key uint64) (v int, ok bool) {
+	if m == nil {
+		return v, false
+	}
+	h := intHash(key)
+
+	h1 := byte(h & 0x7F)
+	h2 := (h >> 7)
+
+	h1Expanded := uint64(h1) * 0x0101_0101_0101_0101
+
+	for seq := makeProbeSeq(h2, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &m.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if e := &g.entries[i]; e.key == key {
+				return e.value, true
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return v, false
+		}
+	}
+}
+
+type uint64Group struct {
+	ctrl    concreteCtrl
+	entries [groupSize]uint64Entry
+}
+
+type uint64Entry struct {
+	key   uint64
+	value int
+}
@@ -0,0 +1,92 @@
+package hashblog_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+	"github.com/philpearl/hashblog/hashblogtest"
+)
+
+func TestSwissUintConformance(t *testing.T) {
+	t.Run("SwissUint64", func(t *testing.T) {
+		hashblogtest.RunConformance(t, hashblog.NewSwissUint64)
+	})
+	t.Run("SwissUint32", func(t *testing.T) {
+		hashblogtest.RunConformance(t, hashblog.NewSwissUint32)
+	})
+	t.Run("SwissTable", func(t *testing.T) {
+		hashblogtest.RunConformance(t, hashblog.NewSwissTable[uint64, int])
+	})
+}
+
+func BenchmarkGetUint64(b *testing.B) {
+	for _, size := range []int{10, 100, 1000, 2000, 4000, 8000, 16000, 24000, 32768} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			b.Run("i=Swiss", func(b *testing.B) {
+				m := hashblog.NewSwissTable[uint64, int]()
+				for i := range size {
+					m.Set(uint64(i), i)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for b.Loop() {
+					for i := range size {
+						if val, ok := m.Get(uint64(i)); !ok || val != i {
+							b.Fatalf("expected key %d to have value %d, got %d", i, i, val)
+						}
+					}
+				}
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+			})
+			b.Run("i=SwissUint64", func(b *testing.B) {
+				m := hashblog.NewSwissUint64()
+				for i := range size {
+					m.Set(uint64(i), i)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for b.Loop() {
+					for i := range size {
+						if val, ok := m.Get(uint64(i)); !ok || val != i {
+							b.Fatalf("expected key %d to have value %d, got %d", i, i, val)
+						}
+					}
+				}
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+			})
+			b.Run("i=SwissUint32", func(b *testing.B) {
+				m := hashblog.NewSwissUint32()
+				for i := range size {
+					m.Set(uint32(i), i)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for b.Loop() {
+					for i := range size {
+						if val, ok := m.Get(uint32(i)); !ok || val != i {
+							b.Fatalf("expected key %d to have value %d, got %d", i, i, val)
+						}
+					}
+				}
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+			})
+			b.Run("i=map", func(b *testing.B) {
+				m := make(map[uint64]int, 32768)
+				for i := range size {
+					m[uint64(i)] = i
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for b.Loop() {
+					for i := range size {
+						if val, ok := m[uint64(i)]; !ok || val != i {
+							b.Fatalf("expected key %d to have value %d, got %d", i, i, val)
+						}
+					}
+				}
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(size), "ns/op")
+			})
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package hashblog
+
+// tombstones counts the deleted markers in a table's groups, so the table
+// knows when to rehash.
+//
+// Deleting from a group with no empty slots has to leave a deleted marker: see
+// GroupTableCtrl.Delete and SwissTable.Delete. Inserts reuse markers they pass
+// on their probe sequence, but with a steady churn of deletes and inserts new
+// markers are left faster than old ones are reused, and inserts of keys that
+// don't pass a marker use up empty slots instead. Only empty slots end probe
+// sequences, so lookups for missing keys probe further and further, and once
+// the empty slots run out they never finish. The same goes for the tables that
+// remove entries themselves: SwissCache when it evicts, SwissTTL when entries
+// expire, and TopK when a new key takes over a counter.
+//
+// So every table that deletes counts its markers. Before inserting a new key
+// it checks tooMany, and if that says so it rehashes: it takes every entry out,
+// empties the groups, and puts the entries back, leaving no markers at all.
+type tombstones int
+
+// deleted records that a delete has freed a slot. marked is whether it left a
+// deleted marker rather than marking the slot empty, as groupCtrl.delete and
+// concreteCtrl.delete report.
+func (t *tombstones) deleted(marked bool) {
+	if marked {
+		*t++
+	}
+}
+
+// reused records that an insert has gone into a slot whose control byte was
+// ctrl.
+func (t *tombstones) reused(ctrl byte) {
+	if ctrl == ctrlDeleted {
+		*t--
+	}
+}
+
+// tooMany reports whether a table with len entries should rehash before
+// inserting another. It says so once the markers take up more than half the
+// slots that aren't in use, so at least half of those are always empty.
+//
+// A rehash costs a pass over the table and an insert for each entry, and it
+// takes at least (simpleTableSize-len)/2 deletes to leave enough markers for
+// the next one. So the cost per delete stays small unless the table is nearly
+// full: at 7/8 full it is about 14 inserts.
+func (t tombstones) tooMany(len int) bool {
+	return t.tooManyIn(len, simpleTableSize)
+}
+
+// tooManyIn is tooMany for a table with slots slots.
+func (t tombstones) tooManyIn(len, slots int) bool {
+	return int(t) > (slots-len)/2
+}
@@ -0,0 +1,27 @@
+package hashblog
+
+// EmptySlots returns the number of empty slots in the set, so tests can check
+// deleted markers don't use them all up.
+func (s *SwissSet[K]) EmptySlots() int {
+	return emptySlots(s.groups[:], func(g *setGroup[K]) groupCtrl { return g.ctrl })
+}
+
+// EmptySlots returns the number of empty slots in the table, so tests can
+// check deleted markers don't use them all up.
+func (m *SwissMultiMap[K, V]) EmptySlots() int {
+	return emptySlots(m.groups[:], func(g *multiGroup[K, V]) groupCtrl { return g.ctrl })
+}
+
+// emptySlots counts the empty slots in groups. ctrl returns a group's control
+// bytes.
+func emptySlots[G any](groups []G, ctrl func(g *G) groupCtrl) int {
+	var n int
+	for gi := range groups {
+		for _, c := range ctrl(&groups[gi]) {
+			if c == 0x80 {
+				n++
+			}
+		}
+	}
+	return n
+}
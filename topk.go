@@ -0,0 +1,245 @@
+package hashblog
+
+import (
+	"cmp"
+	"math/bits"
+	"slices"
+)
+
+// TopKMaxCounters is the largest number of counters a TopK can have. As with
+// SwissCache we keep a quarter of the table free.
+const TopKMaxCounters = simpleTableSize * 3 / 4
+
+// TopK finds the most frequent strings in a stream using the Space-Saving
+// algorithm (Metwally, Agrawal and El Abbadi, 2005).
+//
+// Space-Saving keeps a fixed number of counters. A key with a counter just has
+// its count increased. A key without one takes over the counter with the
+// smallest count, c, and starts counting from c as if it had been there all
+// along. So counts can be too high, but never too low, and each counter
+// remembers c as the most its count can be out by. Every key that occurs more
+// than total/counters times is certain to have a counter.
+//
+// The counters are kept in a min-heap so we can always find the smallest. The
+// groups are laid out like SwissConcrete's, but rather than entries they hold
+// each key's position in the heap, and each counter remembers its slot in the
+// groups so we can update the position as the heap moves it.
+type TopK struct {
+	groups [groupTableSize]topKGroup
+	heap   []topKCounter
+	// counters is the number of counters, fixed when the TopK is made.
+	counters int
+	// tombstones is the number of deleted markers. See tombstones.
+	tombstones tombstones
+	total      int
+}
+
+type topKGroup struct {
+	ctrl concreteCtrl
+	// index holds the position in the heap of the counter in each slot.
+	index [groupSize]int32
+}
+
+type topKCounter struct {
+	key        string
+	count, err int
+	// slot is the counter's slot in the groups: the group times groupSize
+	// plus the slot within the group.
+	slot int32
+}
+
+// TopKItem is a key and its estimated count. The true count is between
+// Count-Err and Count.
+type TopKItem struct {
+	Key   string
+	Count int
+	Err   int
+	// Guaranteed is true if the key is certain to be among the true top k,
+	// because even its smallest possible count is at least the largest
+	// possible count of any key Top didn't return.
+	Guaranteed bool
+}
+
+// NewTopK returns a TopK with the given number of counters. More counters cost
+// more memory but give smaller errors. NewTopK panics if counters is less than
+// 1 or more than TopKMaxCounters.
+func NewTopK(counters int) *TopK {
+	if counters < 1 || counters > TopKMaxCounters {
+		panic("hashblog: TopK counters out of range")
+	}
+	t := &TopK{counters: counters, heap: make([]topKCounter, 0, counters)}
+	for i := range t.groups {
+		t.groups[i].ctrl = concreteCtrl(0x8080_8080_8080_8080)
+	}
+	return t
+}
+
+// Add records n more occurrences of key. Add does nothing if n isn't positive.
+func (t *TopK) Add(key string, n int) {
+	if n <= 0 {
+		return
+	}
+	t.total += n
+	h := concreteHash(key)
+	if g, i := t.find(key, h); g != nil {
+		pos := int(g.index[i])
+		t.heap[pos].count += n
+		t.down(pos)
+		return
+	}
+
+	if len(t.heap) < t.counters {
+		pos := len(t.heap)
+		t.heap = append(t.heap, topKCounter{key: key, count: n, slot: t.insert(h, int32(pos))})
+		t.up(pos)
+		return
+	}
+
+	// key takes over the counter with the smallest count.
+	if t.tombstones.tooMany(len(t.heap)) {
+		t.rehash()
+	}
+	smallest := &t.heap[0]
+	t.remove(smallest.slot)
+	*smallest = topKCounter{key: key, count: smallest.count + n, err: smallest.count, slot: t.insert(h, 0)}
+	t.down(0)
+}
+
+// Top returns the k keys with the largest counts, largest first. Keys with the
+// same count are in order of key.
+func (t *TopK) Top(k int) []TopKItem {
+	sorted := slices.Clone(t.heap)
+	slices.SortFunc(sorted, func(a, b topKCounter) int {
+		if c := cmp.Compare(b.count, a.count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.key, b.key)
+	})
+
+	// Any key we don't return has a count of at most next. A key without a
+	// counter may have lost it to another key, which only happens once the
+	// counters are all in use, and then its count is at most the smallest
+	// count. Otherwise keys without counters have never been seen.
+	k = min(max(k, 0), len(sorted))
+	var next int
+	if k < len(sorted) {
+		next = sorted[k].count
+	}
+	if len(t.heap) == t.counters {
+		next = max(next, t.heap[0].count)
+	}
+
+	top := make([]TopKItem, 0, k)
+	for _, c := range sorted[:k] {
+		top = append(top, TopKItem{
+			Key:        c.key,
+			Count:      c.count,
+			Err:        c.err,
+			Guaranteed: c.count-c.err >= next,
+		})
+	}
+	return top
+}
+
+// Total returns the sum of n over all calls to Add. No count is out by more
+// than Total divided by the number of counters.
+func (t *TopK) Total() int { return t.total }
+
+// down moves the counter at pos down the heap until it is no larger than its
+// children.
+func (t *TopK) down(pos int) {
+	for {
+		smallest := pos
+		for _, child := range [2]int{2*pos + 1, 2*pos + 2} {
+			if child < len(t.heap) && t.heap[child].count < t.heap[smallest].count {
+				smallest = child
+			}
+		}
+		if smallest == pos {
+			return
+		}
+		t.swap(pos, smallest)
+		pos = smallest
+	}
+}
+
+// up moves the counter at pos up the heap until it is no smaller than its
+// parent.
+func (t *TopK) up(pos int) {
+	for pos > 0 {
+		parent := (pos - 1) / 2
+		if t.heap[parent].count <= t.heap[pos].count {
+			return
+		}
+		t.swap(pos, parent)
+		pos = parent
+	}
+}
+
+// swap swaps two counters in the heap, and updates their positions in the
+// groups.
+func (t *TopK) swap(a, b int) {
+	t.heap[a], t.heap[b] = t.heap[b], t.heap[a]
+	t.setIndex(t.heap[a].slot, a)
+	t.setIndex(t.heap[b].slot, b)
+}
+
+func (t *TopK) setIndex(slot int32, pos int) {
+	t.groups[slot/groupSize].index[slot%groupSize] = int32(pos)
+}
+
+// remove removes the key in slot from the groups.
+func (t *TopK) remove(slot int32) {
+	g, i := &t.groups[slot/groupSize], int(slot%groupSize)
+	t.tombstones.deleted(g.ctrl.delete(i))
+}
+
+// insert records that the key with hash h has the counter at pos in the heap,
+// and returns the slot it uses. The key goes in the first empty or deleted
+// slot on its probe sequence, and must not already be in the groups.
+func (t *TopK) insert(h hashValue, pos int32) int32 {
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &t.groups[seq.offset]
+		if avail := g.ctrl.findEmptyOrDeleted(); avail != 0 {
+			i := bits.TrailingZeros64(avail) / 8
+			t.tombstones.reused(g.ctrl.get(i))
+			g.index[i] = pos
+			g.ctrl.set(i, byte(h&0x7F))
+			return int32(seq.offset)*groupSize + int32(i)
+		}
+	}
+}
+
+// rehash rebuilds the groups from the heap, removing the deleted markers. See
+// tombstones.
+func (t *TopK) rehash() {
+	for i := range t.groups {
+		t.groups[i] = topKGroup{ctrl: concreteCtrl(0x8080_8080_8080_8080)}
+	}
+	t.tombstones = 0
+	for pos := range t.heap {
+		c := &t.heap[pos]
+		c.slot = t.insert(concreteHash(c.key), int32(pos))
+	}
+}
+
+// find returns the group and slot holding key, which has hash h, or a nil
+// group if key doesn't have a counter.
+func (t *TopK) find(key string, h hashValue) (*topKGroup, int) {
+	h1Expanded := uint64(h&0x7F) * 0x0101_0101_0101_0101
+
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		g := &t.groups[seq.offset]
+		matches := g.ctrl.findMatches(h1Expanded)
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if t.heap[g.index[i]].key == key {
+				return g, i
+			}
+			matches &= matches - 1
+		}
+		if empties := g.ctrl.findEmpty(); empties != 0 {
+			return nil, 0
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package hashblog_test
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+func TestTopK(t *testing.T) {
+	top := hashblog.NewTopK(2)
+	top.Add("a", 3)
+	top.Add("b", 1)
+	top.Add("ignored", 0)
+	top.Add("ignored", -1)
+
+	got := top.Top(5)
+	want := []hashblog.TopKItem{
+		{Key: "a", Count: 3, Guaranteed: true},
+		{Key: "b", Count: 1, Guaranteed: true},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// c takes over b's counter, so it might have been seen once before. As far
+	// as TopK knows b might have been seen twice, so c isn't guaranteed to
+	// beat it.
+	top.Add("c", 1)
+	got = top.Top(2)
+	want = []hashblog.TopKItem{
+		{Key: "a", Count: 3, Guaranteed: true},
+		{Key: "c", Count: 2, Err: 1, Guaranteed: false},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// c now has the same count as a. Keys with the same count are in order of
+	// key.
+	top.Add("c", 1)
+	got = top.Top(1)
+	want = []hashblog.TopKItem{{Key: "a", Count: 3, Guaranteed: true}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	// c's count could be as low as 3, which still ties with a.
+	top.Add("c", 1)
+	got = top.Top(1)
+	want = []hashblog.TopKItem{{Key: "c", Count: 4, Err: 1, Guaranteed: true}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if top.Total() != 7 {
+		t.Fatalf("expected a total of 7, got %d", top.Total())
+	}
+	if got := top.Top(-1); len(got) != 0 {
+		t.Fatalf("expected nothing, got %v", got)
+	}
+
+	// d takes over b's counter and goes to the top, but might only have been
+	// seen twice.
+	top = hashblog.NewTopK(2)
+	top.Add("a", 5)
+	top.Add("b", 4)
+	top.Add("d", 2)
+	got = top.Top(1)
+	want = []hashblog.TopKItem{{Key: "d", Count: 6, Err: 4, Guaranteed: false}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// Asking for every counter, c still isn't guaranteed: b lost its counter
+	// with a count of 3, and c might have been seen only once.
+	top = hashblog.NewTopK(2)
+	top.Add("a", 5)
+	top.Add("b", 3)
+	top.Add("c", 1)
+	got = top.Top(2)
+	want = []hashblog.TopKItem{
+		{Key: "a", Count: 5, Guaranteed: true},
+		{Key: "c", Count: 4, Err: 3, Guaranteed: false},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestTopKCounters(t *testing.T) {
+	for _, counters := range []int{0, -1, hashblog.TopKMaxCounters + 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic for %d counters", counters)
+				}
+			}()
+			hashblog.NewTopK(counters)
+		}()
+	}
+}
+
+// TestTopKZipf checks the estimates from a Zipfian stream against the true
+// counts.
+func TestTopKZipf(t *testing.T) {
+	for _, test := range []struct {
+		s        float64
+		counters int
+	}{
+		{s: 1.1, counters: 100},
+		{s: 1.1, counters: 1000},
+		{s: 1.5, counters: 100},
+		{s: 2, counters: 20},
+		// Enough counters that most of them change hands many times, so the
+		// groups are rehashed.
+		{s: 1.01, counters: hashblog.TopKMaxCounters},
+	} {
+		t.Run(strconv.FormatFloat(test.s, 'g', -1, 64)+"/"+strconv.Itoa(test.counters), func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(1, 2))
+			zipf := rand.NewZipf(rng, test.s, 1, 1_000_000)
+
+			top := hashblog.NewTopK(test.counters)
+			exact := make(map[string]int)
+			const n = 1_000_000
+			for range n {
+				key := strconv.FormatUint(zipf.Uint64(), 10)
+				count := 1 + rng.IntN(3)
+				top.Add(key, count)
+				exact[key] += count
+			}
+
+			items := top.Top(test.counters)
+			if len(items) != min(test.counters, len(exact)) {
+				t.Fatalf("expected %d items, got %d", test.counters, len(items))
+			}
+			bound := top.Total() / test.counters
+			found := make(map[string]bool, len(items))
+			for i, item := range items {
+				found[item.Key] = true
+				if i > 0 && item.Count > items[i-1].Count {
+					t.Fatalf("items out of order at %d", i)
+				}
+				if trueCount := exact[item.Key]; trueCount > item.Count || trueCount < item.Count-item.Err {
+					t.Fatalf("%q: true count %d is outside %d-%d", item.Key, trueCount, item.Count-item.Err, item.Count)
+				}
+				if item.Err > bound {
+					t.Fatalf("%q: error %d is larger than total/counters = %d", item.Key, item.Err, bound)
+				}
+			}
+			// Every key that occurs more than total/counters times has a
+			// counter.
+			for key, count := range exact {
+				if count > bound && !found[key] {
+					t.Fatalf("%q occurs %d times, more than %d, but has no counter", key, count, bound)
+				}
+			}
+
+			// The keys Top guarantees are in the true top 10 are.
+			type keyCount struct {
+				key   string
+				count int
+			}
+			var all []keyCount
+			for key, count := range exact {
+				all = append(all, keyCount{key, count})
+			}
+			slices.SortFunc(all, func(a, b keyCount) int { return cmp.Compare(b.count, a.count) })
+			const k = 10
+			threshold := all[k-1].count
+			var guaranteed int
+			for _, item := range top.Top(k) {
+				if !item.Guaranteed {
+					continue
+				}
+				guaranteed++
+				if exact[item.Key] < threshold {
+					t.Fatalf("%q is guaranteed in the top %d, but occurs %d times and the top %d need %d", item.Key, k, exact[item.Key], k, threshold)
+				}
+			}
+			t.Logf("%d of the top %d guaranteed", guaranteed, k)
+			if test.counters >= 100 && guaranteed < k/2 {
+				t.Fatalf("only %d of the top %d guaranteed", guaranteed, k)
+			}
+		})
+	}
+}
+
+func BenchmarkTopKAdd(b *testing.B) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	zipf := rand.NewZipf(rng, 1.1, 1, 1_000_000)
+	keys := make([]string, 1<<16)
+	for i := range keys {
+		keys[i] = strconv.FormatUint(zipf.Uint64(), 10)
+	}
+
+	for _, counters := range []int{100, 1000, 10000} {
+		b.Run("counters="+strconv.Itoa(counters), func(b *testing.B) {
+			top := hashblog.NewTopK(counters)
+			for i := range b.N {
+				top.Add(keys[i&(len(keys)-1)], 1)
+			}
+		})
+	}
+}
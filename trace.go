@@ -0,0 +1,250 @@
+package hashblog
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// LookupTrace records each step of a lookup: where the probe sequence went,
+// what the control bytes said at each step, and which keys we compared. It's
+// for debugging keys that are slow to find, and for drawing diagrams.
+//
+// LookupTrace marshals to JSON as you'd expect, and String formats it as text.
+type LookupTrace struct {
+	// Table is the type of table.
+	Table string `json:"table"`
+	// Key is the key, formatted with %v.
+	Key string `json:"key"`
+	// Hash is the hash of the key.
+	Hash uint64 `json:"hash"`
+	// H1 is the part of the hash kept in the control bytes, and H2 is the part
+	// that picks the first group to look at. They're zero for tables without
+	// control bytes, which use the whole hash to pick the first slot or group.
+	H1 uint8  `json:"h1"`
+	H2 uint64 `json:"h2"`
+	// Unit is "slot" if the table probes slot by slot and "group" if it probes
+	// a group at a time.
+	Unit string `json:"unit"`
+	// Steps are the slots or groups visited, in probe order.
+	Steps []TraceStep `json:"steps"`
+	// KeyComparisons is the number of keys compared with the key.
+	KeyComparisons int `json:"key_comparisons"`
+	// Found is true if the key is in the table.
+	Found bool `json:"found"`
+}
+
+// TraceStep is a visit to one slot or group during a lookup.
+type TraceStep struct {
+	// Index is the slot or group number.
+	Index int `json:"index"`
+	// Ctrl is the group's control bytes in hex, in slot order. It's empty for
+	// tables without control bytes.
+	Ctrl string `json:"ctrl,omitempty"`
+	// Matches is the bitmask findMatches returns for the group, and Empty is
+	// the bitmask findEmpty returns. For groups of 8 these have the top bit of
+	// each byte set for a matching slot. For DoubleSwiss's groups of 16 there's
+	// one bit per slot.
+	Matches uint64 `json:"matches"`
+	Empty   uint64 `json:"empty"`
+	// Compared lists the slots in the group whose keys we compared with the
+	// key. For tables that probe slot by slot it's the slot itself, unless the
+	// slot was empty.
+	Compared []int `json:"compared"`
+}
+
+func (t *LookupTrace) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s lookup of %q\n", t.Table, t.Key)
+	if len(t.Steps) != 0 && t.Steps[0].Ctrl != "" {
+		fmt.Fprintf(&b, "hash %#016x: h1 %#02x, h2 %#x\n", t.Hash, t.H1, t.H2)
+	} else {
+		fmt.Fprintf(&b, "hash %#016x\n", t.Hash)
+	}
+	for _, s := range t.Steps {
+		fmt.Fprintf(&b, "  %s %d:", t.Unit, s.Index)
+		if s.Ctrl != "" {
+			fmt.Fprintf(&b, " ctrl [%s] matches %#x empty %#x", s.Ctrl, s.Matches, s.Empty)
+		}
+		if len(s.Compared) != 0 {
+			fmt.Fprintf(&b, " compared %v", s.Compared)
+		}
+		b.WriteByte('\n')
+	}
+	outcome := "not found"
+	if t.Found {
+		outcome = "found"
+	}
+	fmt.Fprintf(&b, "%s after %d %ss and %d key comparisons\n", outcome, len(t.Steps), t.Unit, t.KeyComparisons)
+	return b.String()
+}
+
+func newTrace(table string, key any, h hashValue, unit string) LookupTrace {
+	return LookupTrace{Table: table, Key: fmt.Sprint(key), Hash: uint64(h), Unit: unit}
+}
+
+// compare records a key comparison at slot i of the current step.
+func (t *LookupTrace) compare(i int, equal bool) {
+	s := &t.Steps[len(t.Steps)-1]
+	s.Compared = append(s.Compared, i)
+	t.KeyComparisons++
+	t.Found = equal
+}
+
+// ctrlString formats control bytes for TraceStep.Ctrl.
+func ctrlString(ctrl []byte) string {
+	return fmt.Sprintf("% x", ctrl)
+}
+
+// Trace looks up key, recording each slot visited.
+func (st *SimpleTable[K, V]) Trace(key K) LookupTrace {
+	h := hash(key)
+	t := newTrace("SimpleTable", key, h, "slot")
+	var zero K
+	for index := h % simpleTableSize; ; index = (index + 1) % simpleTableSize {
+		t.Steps = append(t.Steps, TraceStep{Index: int(index)})
+		e := &st.entries[index]
+		if e.key == zero && key != zero {
+			return t
+		}
+		if t.compare(int(index), e.key == key); t.Found {
+			return t
+		}
+	}
+}
+
+// Trace looks up key, recording each slot visited.
+func (st *SimpleTableProbe[K, V]) Trace(key K) LookupTrace {
+	h := hash(key)
+	t := newTrace("SimpleTableProbe", key, h, "slot")
+	var zero K
+	for seq := makeProbeSeq(h, hashValue(simpleTableSize-1)); ; seq = seq.next() {
+		t.Steps = append(t.Steps, TraceStep{Index: int(seq.offset)})
+		e := &st.entries[seq.offset]
+		if e.key == zero && key != zero {
+			return t
+		}
+		if t.compare(int(seq.offset), e.key == key); t.Found {
+			return t
+		}
+	}
+}
+
+// Trace looks up key, recording each group visited and the slots compared in
+// each.
+func (m *GroupTable[K, V]) Trace(key K) LookupTrace {
+	h := hash(key)
+	t := newTrace("GroupTable", key, h, "group")
+	var zero K
+	for seq := makeProbeSeq(h, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		t.Steps = append(t.Steps, TraceStep{Index: int(seq.offset)})
+		g := &m.groups[seq.offset]
+		for i := range g {
+			e := &g[i]
+			if e.key == zero && key != zero {
+				return t
+			}
+			if t.compare(i, e.key == key); t.Found {
+				return t
+			}
+		}
+	}
+}
+
+// traceGroups does the work of Trace for the tables with groups of 8 and
+// control bytes. ctrl returns the control bytes of group gi, and equal reports
+// whether the key in slot i of group gi is the one we're looking for.
+func traceGroups(t *LookupTrace, h hashValue, ctrl func(gi int) concreteCtrl, equal func(gi, i int) bool) {
+	t.H1, t.H2 = byte(h&0x7F), uint64(h>>7)
+	h1Expanded := uint64(t.H1) * 0x0101_0101_0101_0101
+	for seq := makeProbeSeq(h>>7, hashValue(groupTableSize-1)); ; seq = seq.next() {
+		gi := int(seq.offset)
+		c := ctrl(gi)
+		matches, empties := c.findMatches(h1Expanded), c.findEmpty()
+		var bytes [groupSize]byte
+		for i := range bytes {
+			bytes[i] = c.get(i)
+		}
+		t.Steps = append(t.Steps, TraceStep{Index: gi, Ctrl: ctrlString(bytes[:]), Matches: matches, Empty: empties})
+		for matches != 0 {
+			i := bits.TrailingZeros64(matches) / 8
+			if t.compare(i, equal(gi, i)); t.Found {
+				return
+			}
+			matches &= matches - 1
+		}
+		if empties != 0 {
+			return
+		}
+	}
+}
+
+// Trace looks up key, recording each group visited.
+//
+// GroupTableCtrl checks the control bytes one at a time rather than using
+// findMatches and findEmpty, but as it never leaves a key after an empty slot
+// in a group it compares the same keys, so the trace shows the bitmasks anyway.
+func (m *GroupTableCtrl[K, V]) Trace(key K) LookupTrace {
+	h := hash(key)
+	t := newTrace("GroupTableCtrl", key, h, "group")
+	traceGroups(&t, h,
+		func(gi int) concreteCtrl { return concreteCtrl(m.groups[gi].ctrl.toBitmask()) },
+		func(gi, i int) bool { return m.groups[gi].entries[i].key == key },
+	)
+	return t
+}
+
+// Trace looks up key, recording each group visited.
+func (m *SwissTable[K, V]) Trace(key K) LookupTrace {
+	h := hash(key)
+	t := newTrace("SwissTable", key, h, "group")
+	traceGroups(&t, h,
+		func(gi int) concreteCtrl { return concreteCtrl(m.groups[gi].ctrl.toBitmask()) },
+		func(gi, i int) bool { return m.groups[gi].entries[i].key == key },
+	)
+	return t
+}
+
+// Trace looks up key, recording each group visited.
+func (m *SwissConcrete) Trace(key string) LookupTrace {
+	h := concreteHash(key)
+	t := newTrace("SwissConcrete", key, h, "group")
+	traceGroups(&t, h,
+		func(gi int) concreteCtrl { return m.groups[gi].ctrl },
+		func(gi, i int) bool { return m.groups[gi].entries[i].key == key },
+	)
+	return t
+}
+
+// Trace looks up key, recording each group visited.
+func (m *SwissArena) Trace(key string) LookupTrace {
+	h := concreteHash(key)
+	t := newTrace("SwissArena", key, h, "group")
+	traceGroups(&t, h,
+		func(gi int) concreteCtrl { return m.groups[gi].ctrl },
+		func(gi, i int) bool { return m.arena.equal(m.groups[gi].entries[i].key, key) },
+	)
+	return t
+}
+
+// Trace looks up key, recording each group visited.
+func (m *SwissUint64) Trace(key uint64) LookupTrace {
+	h := intHash(key)
+	t := newTrace("SwissUint64", key, h, "group")
+	traceGroups(&t, h,
+		func(gi int) concreteCtrl { return m.groups[gi].ctrl },
+		func(gi, i int) bool { return m.groups[gi].entries[i].key == key },
+	)
+	return t
+}
+
+// Trace looks up key, recording each group visited.
+func (m *SwissUint32) Trace(key uint32) LookupTrace {
+	h := intHash(uint64(key))
+	t := newTrace("SwissUint32", key, h, "group")
+	traceGroups(&t, h,
+		func(gi int) concreteCtrl { return m.groups[gi].ctrl },
+		func(gi, i int) bool { return m.groups[gi].keys[i] == key },
+	)
+	return t
+}
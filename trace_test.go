@@ -0,0 +1,114 @@
+package hashblog_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+type tracer interface {
+	mapper
+	Trace(key string) hashblog.LookupTrace
+}
+
+func TestTrace(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		m    tracer
+	}{
+		{"SimpleTable", hashblog.NewSimpleTable[string, int]()},
+		{"SimpleTableProbe", hashblog.NewSimpleTableProbe[string, int]()},
+		{"GroupTable", hashblog.NewGroupTable[string, int]()},
+		{"GroupTableCtrl", hashblog.NewGroupTableCtrl[string, int]()},
+		{"SwissTable", hashblog.NewSwissTable[string, int]()},
+		{"SwissConcrete", hashblog.NewSwissConcrete()},
+		{"DoubleSwiss", hashblog.NewDoubleSwiss()},
+		{"SwissArena", hashblog.NewSwissArena()},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m := test.m
+			for i := range 20000 {
+				m.Set(strconv.Itoa(i), i)
+			}
+
+			for i := 19000; i < 21000; i++ {
+				key := strconv.Itoa(i)
+				tr := m.Trace(key)
+				_, ok := m.Get(key)
+				if tr.Found != ok {
+					t.Fatalf("trace of %q says found is %t, Get says %t", key, tr.Found, ok)
+				}
+				if len(tr.Steps) == 0 {
+					t.Fatalf("trace of %q has no steps", key)
+				}
+				var compared int
+				for _, s := range tr.Steps {
+					compared += len(s.Compared)
+				}
+				if compared != tr.KeyComparisons {
+					t.Fatalf("trace of %q has %d comparisons in the steps but KeyComparisons is %d", key, compared, tr.KeyComparisons)
+				}
+				if ok && tr.KeyComparisons == 0 {
+					t.Fatalf("trace of %q found the key without comparing it", key)
+				}
+			}
+		})
+	}
+}
+
+func TestTraceFormat(t *testing.T) {
+	m := hashblog.NewSwissTable[string, int]()
+	m.Set("a", 1)
+
+	tr := m.Trace("a")
+	if !tr.Found || tr.KeyComparisons != 1 || len(tr.Steps) != 1 {
+		t.Fatalf("unexpected trace %#v", tr)
+	}
+	s := tr.Steps[0]
+	if s.Compared[0] != 0 || s.Matches != 0x80 || s.Empty != 0x8080_8080_8080_8000 {
+		t.Fatalf("unexpected step %#v", s)
+	}
+	if want := fmt.Sprintf("%02x 80", tr.H1); !strings.HasPrefix(s.Ctrl, want) {
+		t.Fatalf("expected control bytes to start with %s, got %s", want, s.Ctrl)
+	}
+
+	text := tr.String()
+	for _, want := range []string{`SwissTable lookup of "a"`, "h1 ", "group ", "compared [0]", "found after 1 groups and 1 key comparisons"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in trace text\n%s", want, text)
+		}
+	}
+
+	data, err := json.Marshal(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back hashblog.LookupTrace
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.String() != text {
+		t.Fatalf("trace changed when round-tripped through JSON: %s", data)
+	}
+}
+
+func TestTraceUint(t *testing.T) {
+	m64 := hashblog.NewSwissUint64()
+	m32 := hashblog.NewSwissUint32()
+	for i := range 1000 {
+		m64.Set(uint64(i), i)
+		m32.Set(uint32(i), i)
+	}
+	for i := range 2000 {
+		if tr := m64.Trace(uint64(i)); tr.Found != (i < 1000) {
+			t.Fatalf("SwissUint64 trace of %d says found is %t", i, tr.Found)
+		}
+		if tr := m32.Trace(uint32(i)); tr.Found != (i < 1000) {
+			t.Fatalf("SwissUint32 trace of %d says found is %t", i, tr.Found)
+		}
+	}
+}
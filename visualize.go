@@ -0,0 +1,184 @@
+package hashblog
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+)
+
+// VizFormat is the output format for Visualize.
+type VizFormat string
+
+const (
+	// VizSVG is a bare SVG image.
+	VizSVG VizFormat = "svg"
+	// VizHTML is a self-contained HTML page with the image, a legend and the
+	// table's Stats.
+	VizHTML VizFormat = "html"
+)
+
+// Visualize draws the table's control bytes as a grid, with each group a row
+// of slots. Empty and deleted slots are grey and black. Slots in use are
+// coloured by the length of the probe sequence a lookup follows to reach them.
+// Hovering over a slot shows its h1 value and probe length.
+func (m *GroupTableCtrl[K, V]) Visualize(w io.Writer, format VizFormat) error {
+	slots := make([]vizSlot, 0, simpleTableSize)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i, ctrl := range g.ctrl {
+			s := vizSlot{ctrl: ctrl}
+			if ctrl < 0x80 {
+				s.probe = probeLength(hash(g.entries[i].key)>>7, groupTableSize-1, gi)
+			}
+			slots = append(slots, s)
+		}
+	}
+	stats := m.Stats()
+	return visualize(w, format, "GroupTableCtrl", groupSize, slots, &stats)
+}
+
+// Visualize draws the table's control bytes. See GroupTableCtrl.Visualize.
+func (m *SwissTable[K, V]) Visualize(w io.Writer, format VizFormat) error {
+	slots := make([]vizSlot, 0, simpleTableSize)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i, ctrl := range g.ctrl {
+			s := vizSlot{ctrl: ctrl}
+			if ctrl < 0x80 {
+				s.probe = probeLength(hash(g.entries[i].key)>>7, groupTableSize-1, gi)
+			}
+			slots = append(slots, s)
+		}
+	}
+	stats := m.Stats()
+	return visualize(w, format, "SwissTable", groupSize, slots, &stats)
+}
+
+// Visualize draws the table's control bytes. See GroupTableCtrl.Visualize.
+//
+// This is also what NewDoubleSwiss returns when SIMD isn't available.
+func (m *SwissConcrete) Visualize(w io.Writer, format VizFormat) error {
+	slots := make([]vizSlot, 0, simpleTableSize)
+	for gi := range m.groups {
+		g := &m.groups[gi]
+		for i := range groupSize {
+			ctrl := g.ctrl.get(i)
+			s := vizSlot{ctrl: ctrl}
+			if ctrl < 0x80 {
+				s.probe = probeLength(concreteHash(g.entries[i].key)>>7, groupTableSize-1, gi)
+			}
+			slots = append(slots, s)
+		}
+	}
+	stats := m.Stats()
+	return visualize(w, format, "SwissConcrete", groupSize, slots, &stats)
+}
+
+// vizSlot is what Visualize needs to know about each slot.
+type vizSlot struct {
+	ctrl byte
+	// probe is the number of groups a lookup visits to find the key in this
+	// slot. It's zero if the slot isn't in use.
+	probe int
+}
+
+// Sizes in the SVG, in pixels.
+const (
+	vizCell     = 6
+	vizGroupGap = 4
+	vizMargin   = 10
+	// vizRows is the number of groups in each column of the grid.
+	vizRows = 64
+)
+
+// vizColours are the colours for slots in use, indexed by probe length. Keys
+// with longer probe lengths than this get the last colour.
+var vizColours = []string{
+	1: "#4caf50",
+	2: "#cddc39",
+	3: "#ffc107",
+	4: "#ff9800",
+	5: "#f44336",
+}
+
+const (
+	vizEmptyColour   = "#eeeeee"
+	vizDeletedColour = "#212121"
+)
+
+func vizColour(probe int) string {
+	return vizColours[min(probe, len(vizColours)-1)]
+}
+
+// visualize writes slots, which are laid out groupSize to a group, in format.
+func visualize(w io.Writer, format VizFormat, name string, groupSize int, slots []vizSlot, stats *Stats) error {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case VizSVG:
+		writeSVG(bw, groupSize, slots)
+	case VizHTML:
+		writeHTML(bw, name, groupSize, slots, stats)
+	default:
+		return fmt.Errorf("unknown visualization format %q", format)
+	}
+	return bw.Flush()
+}
+
+func writeSVG(w *bufio.Writer, groupSize int, slots []vizSlot) {
+	numGroups := len(slots) / groupSize
+	columns := (numGroups + vizRows - 1) / vizRows
+	groupWidth := groupSize * vizCell
+	width := 2*vizMargin + columns*(groupWidth+vizGroupGap) - vizGroupGap
+	height := 2*vizMargin + min(numGroups, vizRows)*vizCell
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	fmt.Fprintf(w, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	for gi := range numGroups {
+		x := vizMargin + (gi/vizRows)*(groupWidth+vizGroupGap)
+		y := vizMargin + (gi%vizRows)*vizCell
+
+		// Draw the whole group as empty, then draw over the slots that aren't.
+		// Most slots in a sparse table are empty, so this keeps the file small.
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"><title>group %d</title></rect>`+"\n", x, y, groupWidth, vizCell, vizEmptyColour, gi)
+		for i, s := range slots[gi*groupSize : (gi+1)*groupSize] {
+			switch {
+			case s.ctrl == ctrlDeleted:
+				fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"><title>group %d slot %d: deleted</title></rect>`+"\n", x+i*vizCell, y, vizCell, vizCell, vizDeletedColour, gi, i)
+			case s.ctrl < 0x80:
+				fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"><title>group %d slot %d: h1 0x%02x, probe length %d</title></rect>`+"\n", x+i*vizCell, y, vizCell, vizCell, vizColour(s.probe), gi, i, s.ctrl, s.probe)
+			}
+		}
+	}
+	w.WriteString("</svg>\n")
+}
+
+func writeHTML(w *bufio.Writer, name string, groupSize int, slots []vizSlot, stats *Stats) {
+	title := html.EscapeString(name)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { font-family: sans-serif; }
+.legend span { display: inline-block; width: 1em; height: 1em; margin: 0 0.3em 0 1em; vertical-align: middle; border: 1px solid #999; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+<p>Each row of %d cells is a group. Groups run down the columns, then left to right.</p>
+<p class="legend">`, title, title, groupSize)
+	fmt.Fprintf(w, `<span style="background: %s"></span>empty`, vizEmptyColour)
+	fmt.Fprintf(w, `<span style="background: %s"></span>deleted`, vizDeletedColour)
+	for probe := 1; probe < len(vizColours); probe++ {
+		label := fmt.Sprintf("probe length %d", probe)
+		if probe == len(vizColours)-1 {
+			label += "+"
+		}
+		fmt.Fprintf(w, `<span style="background: %s"></span>%s`, vizColours[probe], label)
+	}
+	w.WriteString("</p>\n")
+	writeSVG(w, groupSize, slots)
+	fmt.Fprintf(w, "<pre>%s</pre>\n</body>\n</html>\n", html.EscapeString(stats.String()))
+}
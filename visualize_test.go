@@ -0,0 +1,82 @@
+package hashblog_test
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/philpearl/hashblog"
+)
+
+type visualizer interface {
+	mapper
+	deleter
+	Visualize(w io.Writer, format hashblog.VizFormat) error
+}
+
+func TestVisualize(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		m    visualizer
+	}{
+		{"GroupTableCtrl", hashblog.NewGroupTableCtrl[string, int]()},
+		{"SwissTable", hashblog.NewSwissTable[string, int]()},
+		{"SwissConcrete", hashblog.NewSwissConcrete()},
+		{"DoubleSwiss", hashblog.NewDoubleSwiss()},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m := test.m
+			for i := range 1000 {
+				m.Set(strconv.Itoa(i), i)
+			}
+			for i := range 10 {
+				m.Delete(strconv.Itoa(i))
+			}
+
+			var svg bytes.Buffer
+			if err := m.Visualize(&svg, hashblog.VizSVG); err != nil {
+				t.Fatal(err)
+			}
+			out := svg.String()
+			if !strings.HasPrefix(out, "<svg ") || !strings.HasSuffix(out, "</svg>\n") {
+				t.Fatalf("output doesn't look like an SVG: %.100s", out)
+			}
+			if n := strings.Count(out, "probe length"); n != 990 {
+				t.Errorf("expected 990 slots in use, got %d", n)
+			}
+			// Deleting may mark slots empty rather than deleted, so we can only
+			// check there are no more deleted slots than deletes.
+			if n := strings.Count(out, ": deleted"); n > 10 {
+				t.Errorf("expected at most 10 deleted slots, got %d", n)
+			}
+
+			var page bytes.Buffer
+			if err := m.Visualize(&page, hashblog.VizHTML); err != nil {
+				t.Fatal(err)
+			}
+			out = page.String()
+			if !strings.HasPrefix(out, "<!DOCTYPE html>") {
+				t.Fatalf("output doesn't look like HTML: %.100s", out)
+			}
+			if !strings.Contains(out, svg.String()) {
+				t.Errorf("HTML page doesn't contain the SVG")
+			}
+			if !strings.Contains(out, "entries: 990/32768") {
+				t.Errorf("HTML page doesn't contain the table stats")
+			}
+		})
+	}
+}
+
+func TestVisualizeUnknownFormat(t *testing.T) {
+	m := hashblog.NewSwissTable[string, int]()
+	var buf bytes.Buffer
+	if err := m.Visualize(&buf, "png"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output for an unknown format, got %d bytes", buf.Len())
+	}
+}
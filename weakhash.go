@@ -0,0 +1,21 @@
+//go:build weakhash
+
+package hashblog
+
+// With the weakhash build tag, tests can replace every hash with a weaker one
+// to force keys to collide. That lets them exercise long probe sequences and
+// full groups, which a good hash makes very rare.
+//
+// We use a build tag rather than just checking whether testHash is set because
+// even that check costs a couple of nanoseconds a lookup.
+
+// testHash, if set, is applied to every hash. See export_weakhash_test.go for
+// how tests set it.
+var testHash func(h hashValue) hashValue
+
+func weaken(h hashValue) hashValue {
+	if testHash != nil {
+		h = testHash(h)
+	}
+	return h
+}
@@ -0,0 +1,9 @@
+//go:build !weakhash
+
+package hashblog
+
+// weaken does nothing unless we're built with the weakhash tag. See
+// weakhash.go.
+func weaken(h hashValue) hashValue {
+	return h
+}
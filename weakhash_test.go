@@ -0,0 +1,122 @@
+//go:build weakhash
+
+package hashblog_test
+
+import (
+	"testing"
+
+	"github.com/philpearl/hashblog"
+	"github.com/philpearl/hashblog/hashblogtest"
+)
+
+// TestWeakHash runs the conformance tests with hashes that force keys to
+// collide. Run these with go test -tags weakhash.
+func TestWeakHash(t *testing.T) {
+	for _, weak := range []struct {
+		name string
+		hash func(uint64) uint64
+	}{
+		{"Constant", hashblog.ConstantHash},
+		{"LowEntropy", hashblog.LowEntropyHash},
+		{"SameH1", hashblog.SameH1Hash},
+		{"SameGroup", hashblog.SameGroupHash},
+	} {
+		t.Run(weak.name, func(t *testing.T) {
+			defer hashblog.SetWeakHash(weak.hash)()
+
+			// With a weak hash many keys share a probe sequence, so each
+			// operation costs time proportional to the number of keys. We keep
+			// the number of keys down so the tests finish quickly.
+			opts := []hashblogtest.Option{hashblogtest.Capacity(1024)}
+			for _, test := range []struct {
+				name      string
+				new       func() mapper
+				noZeroKey bool
+			}{
+				{"SimpleTable", func() mapper { return hashblog.NewSimpleTable[string, int]() }, true},
+				{"SimpleTableProbe", func() mapper { return hashblog.NewSimpleTableProbe[string, int]() }, true},
+				{"GroupTable", func() mapper { return hashblog.NewGroupTable[string, int]() }, true},
+				{"GroupTableCtrl", func() mapper { return hashblog.NewGroupTableCtrl[string, int]() }, false},
+				{"SwissTable", func() mapper { return hashblog.NewSwissTable[string, int]() }, false},
+				{"SwissConcrete", func() mapper { return hashblog.NewSwissConcrete() }, false},
+				{"DoubleSwiss", func() mapper { return hashblog.NewDoubleSwiss() }, false},
+				{"SwissArena", func() mapper { return hashblog.NewSwissArena() }, false},
+			} {
+				t.Run(test.name, func(t *testing.T) {
+					opts := opts
+					if test.noZeroKey {
+						opts = append(opts, hashblogtest.NoZeroKey())
+					}
+					hashblogtest.RunConformance(t, test.new, opts...)
+				})
+			}
+			t.Run("SwissUint64", func(t *testing.T) {
+				hashblogtest.RunConformance(t, hashblog.NewSwissUint64, opts...)
+			})
+			t.Run("SwissUint32", func(t *testing.T) {
+				hashblogtest.RunConformance(t, hashblog.NewSwissUint32, opts...)
+			})
+		})
+	}
+}
+
+// FuzzOpsWeakHash is FuzzOps with hashes that force keys to collide: first
+// every key gets the same h1, so every control byte in use matches, and then
+// every key starts in the same group. Run it with go test -tags weakhash.
+func FuzzOpsWeakHash(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, weak := range []struct {
+			name string
+			hash func(uint64) uint64
+		}{
+			{"SameH1", hashblog.SameH1Hash},
+			{"SameGroup", hashblog.SameGroupHash},
+		} {
+			t.Run(weak.name, func(t *testing.T) {
+				defer hashblog.SetWeakHash(weak.hash)()
+				runFuzzTables(t, data)
+			})
+		}
+	})
+}
+
+// TestWeakHashFullGroups checks that with every key starting in the same group
+// we fill that group and carry on to the next ones, and that lookups for missing
+// keys still terminate.
+func TestWeakHashFullGroups(t *testing.T) {
+	defer hashblog.SetWeakHash(hashblog.ConstantHash)()
+
+	m := hashblog.NewSwissTable[int, int]()
+	for i := range 100 {
+		m.Set(i, i)
+	}
+	for i := range 100 {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Fatalf("expected key %d to have value %d, got %d, %t", i, i, val, ok)
+		}
+	}
+	if _, ok := m.Get(100); ok {
+		t.Fatalf("expected missing key to return ok == false")
+	}
+
+	// Delete everything in the first, full, group. That leaves deleted
+	// markers, and lookups must carry on past them.
+	for i := range 8 {
+		m.Delete(i)
+	}
+	for i := 8; i < 100; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Fatalf("expected key %d to have value %d, got %d, %t", i, i, val, ok)
+		}
+	}
+	// Add keys that will go in the deleted slots.
+	for i := 100; i < 108; i++ {
+		m.Set(i, i)
+	}
+	for i := 8; i < 108; i++ {
+		if val, ok := m.Get(i); !ok || val != i {
+			t.Fatalf("expected key %d to have value %d, got %d, %t", i, i, val, ok)
+		}
+	}
+}